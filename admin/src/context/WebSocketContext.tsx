@@ -9,12 +9,21 @@ export const WebsocketProvider: React.FC<{ children: React.ReactNode }> = ({ chi
   const [isReady, setIsReady] = useState(false);
   const [val, setVal] = useState(null);
   const ws = useRef<WebSocket | null>(null);
+  const lastSeq = useRef<number | null>(null);
 
   useEffect(() => {
     const connectWebSocket = () => {
       const socket = new WebSocket("ws://localhost:8080/socket/players");
 
-      socket.onopen = () => setIsReady(true);
+      socket.onopen = () => {
+        // The server closes the socket unless the first message carries the login token
+        socket.send(JSON.stringify({ action: "auth", token: localStorage.getItem("token") }));
+        // Catch up on the changes missed while disconnected
+        if (lastSeq.current !== null) {
+          socket.send(JSON.stringify({ action: "resume", seq: lastSeq.current }));
+        }
+        setIsReady(true);
+      };
       socket.onclose = () => {
         setIsReady(false);
         // Retry connection after 1 second
@@ -22,6 +31,14 @@ export const WebsocketProvider: React.FC<{ children: React.ReactNode }> = ({ chi
       };
       socket.onmessage = (event) => {
         console.log("Received message:", event.data); // Log received messages
+        // Live changes can arrive before the missed ones, so keep the highest sequence number
+        const message = JSON.parse(event.data);
+        const seqs = message.action === "resumed" ? message.events.map((e: { seq: number }) => e.seq) : [message.seq];
+        for (const seq of seqs) {
+          if (seq !== undefined && (lastSeq.current === null || seq > lastSeq.current)) {
+            lastSeq.current = seq;
+          }
+        }
         setVal(event.data);
       };
 
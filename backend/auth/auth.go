@@ -1,6 +1,7 @@
 package auth
 
 import (
+	"errors"
 	"net/http"
 	"os"
 	"strings"
@@ -11,6 +12,8 @@ import (
 
 var jwtKey = []byte(os.Getenv("JWT_SECRET"))
 
+var ErrInvalidToken = errors.New("invalid token")
+
 type Claims struct {
 	Username string `json:"username"`
 	Role     string `json:"role"`
@@ -28,13 +31,9 @@ func UserAuth() gin.HandlerFunc {
 		}
 
 		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
-		claims := &Claims{}
-
-		token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-			return jwtKey, nil
-		})
+		claims, err := ParseToken(tokenString)
 		if err != nil {
-			if err == jwt.ErrSignatureInvalid {
+			if err == jwt.ErrSignatureInvalid || err == ErrInvalidToken {
 				c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
 				c.Abort()
 				return
@@ -44,12 +43,6 @@ func UserAuth() gin.HandlerFunc {
 			return
 		}
 
-		if !token.Valid {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
-			c.Abort()
-			return
-		}
-
 		c.Set("username", claims.Username)
 		c.Set("role", claims.Role)
 		c.Set("user_id", claims.UserID)
@@ -57,6 +50,21 @@ func UserAuth() gin.HandlerFunc {
 	}
 }
 
+// ParseToken checks the signature and expiry of the token and returns its claims
+func ParseToken(tokenString string) (*Claims, error) {
+	claims := &Claims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		return jwtKey, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !token.Valid {
+		return nil, ErrInvalidToken
+	}
+	return claims, nil
+}
+
 func AdminAuth() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		role, exists := c.Get("role")
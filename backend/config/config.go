@@ -3,6 +3,8 @@ package config
 import (
 	"log"
 	"os"
+	"strconv"
+	"strings"
 
 	"github.com/joho/godotenv"
 )
@@ -10,6 +12,32 @@ import (
 // Initialize the configuration
 var Port, DBUser, DBPassword, DBName, DBHost, DBPort string
 
+// Transfer market settings
+var FreeTransfersPerRound, TransferPenaltyPoints int
+
+// Percentage of a player's price rise since purchase that a team gets back when selling the player
+var SellingPriceRisePercent int
+
+// Squad composition rules, a maximum of 0 disables the rule
+var MinBatsmen, MaxBatsmen, MinBowlers, MaxBowlers, MinAllRounders, MaxAllRounders, MaxPlayersPerUniversity int
+
+// Number of substitutes a squad can name on its bench
+var BenchSize int
+
+// Market mode moves player prices by demand every interval, an interval of 0 disables it. Every
+// MarketTransfersPerStep net transfers move the price by MarketPriceStep, at most MarketMaxStepsPerRun
+// steps per run and MarketMaxAdjustment away from the value given by the scoring rules.
+var MarketIntervalMinutes, MarketTransfersPerStep, MarketPriceStep, MarketMaxStepsPerRun, MarketMaxAdjustment int
+
+// Browser origins allowed to open the live updates socket
+var SocketAllowedOrigins []string
+
+// Number of recent change events kept for clients resuming after a dropped connection
+var EventLogSize int
+
+// Milliseconds player changes are collected before the affected teams are recalculated together
+var TeamRecomputeDebounceMs int
+
 func LoadConfig() {
 	err := godotenv.Load(".env")
 	if err != nil {
@@ -23,4 +51,60 @@ func LoadConfig() {
 	DBName = os.Getenv("DB_NAME")
 	DBHost = os.Getenv("DB_HOST")
 	DBPort = os.Getenv("DB_PORT")
+
+	FreeTransfersPerRound = getEnvInt("FREE_TRANSFERS_PER_ROUND", 1)
+	TransferPenaltyPoints = getEnvInt("TRANSFER_PENALTY_POINTS", 4)
+	SellingPriceRisePercent = getEnvInt("SELLING_PRICE_RISE_PERCENT", 50)
+
+	MinBatsmen = getEnvInt("SQUAD_MIN_BATSMEN", 3)
+	MaxBatsmen = getEnvInt("SQUAD_MAX_BATSMEN", 7)
+	MinBowlers = getEnvInt("SQUAD_MIN_BOWLERS", 3)
+	MaxBowlers = getEnvInt("SQUAD_MAX_BOWLERS", 6)
+	MinAllRounders = getEnvInt("SQUAD_MIN_ALL_ROUNDERS", 1)
+	MaxAllRounders = getEnvInt("SQUAD_MAX_ALL_ROUNDERS", 4)
+	MaxPlayersPerUniversity = getEnvInt("SQUAD_MAX_PLAYERS_PER_UNIVERSITY", 4)
+	BenchSize = getEnvInt("SQUAD_BENCH_SIZE", 4)
+
+	TeamRecomputeDebounceMs = getEnvInt("TEAM_RECOMPUTE_DEBOUNCE_MS", 500)
+
+	SocketAllowedOrigins = getEnvList("SOCKET_ALLOWED_ORIGINS", "http://localhost:5173,http://localhost:3000")
+	EventLogSize = getEnvInt("EVENT_LOG_SIZE", 1000)
+	if EventLogSize < 0 {
+		log.Fatalf("Invalid value for EVENT_LOG_SIZE: %d is negative", EventLogSize)
+	}
+
+	MarketIntervalMinutes = getEnvInt("MARKET_INTERVAL_MINUTES", 0)
+	MarketTransfersPerStep = getEnvInt("MARKET_TRANSFERS_PER_STEP", 10)
+	MarketPriceStep = getEnvInt("MARKET_PRICE_STEP", 50000)
+	MarketMaxStepsPerRun = getEnvInt("MARKET_MAX_STEPS_PER_RUN", 1)
+	MarketMaxAdjustment = getEnvInt("MARKET_MAX_ADJUSTMENT", 500000)
+}
+
+// getEnvInt reads an integer environment variable, falling back to the default when it is unset
+func getEnvInt(key string, fallback int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	number, err := strconv.Atoi(value)
+	if err != nil {
+		log.Fatalf("Invalid value for %s: %v", key, err)
+	}
+	return number
+}
+
+// getEnvList reads a comma separated environment variable, falling back to the default when it is unset
+func getEnvList(key string, fallback string) []string {
+	value, ok := os.LookupEnv(key)
+	if !ok {
+		value = fallback
+	}
+
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
 }
@@ -0,0 +1,104 @@
+// handlers/event.handler.go
+package handlers
+
+import (
+	"fmt"
+	"go-orm-template/auth"
+	"go-orm-template/models"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Comments sent on an idle stream, so proxies do not close it
+const eventStreamPingPeriod = 30 * time.Second
+
+// StreamEvents streams the change events of the websocket as server-sent events, for clients behind
+// proxies that break websockets. Clients authenticate with a stream ticket as the ticket query parameter
+// or the API's JWT in the Authorization header. Topics are given as ?topics=player:12,leaderboard,
+// without topics every change is sent. Each event carries its sequence number as id, which a client
+// sends back in Last-Event-ID, or the last_event_id query parameter when it reconnects with a new
+// ticket, to first receive the changes it missed, or a resync event when they are no longer kept.
+func StreamEvents(c *gin.Context) {
+	claims, err := requestClaims(c)
+	if err == nil && claims == nil {
+		err = auth.ErrInvalidToken
+	}
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	var topics []string
+	if query := c.Query("topics"); query != "" {
+		topics = strings.Split(query, ",")
+	}
+	lastEventID := c.GetHeader("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = c.Query("last_event_id")
+	}
+	var lastSeq *uint64
+	if lastEventID != "" {
+		seq, err := strconv.ParseUint(lastEventID, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Last-Event-ID"})
+			return
+		}
+		lastSeq = &seq
+	}
+
+	client, missed, ok, err := models.SocketHub.Listen(claims.UserID, claims.Role, topics, lastSeq)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	defer client.Close()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+
+	// The resync event moves Last-Event-ID to the present, so the next reconnect resumes from here
+	if !ok {
+		writeServerEvent(c.Writer, "resync", client.Joined(), []byte(`{"action":"resync"}`))
+	}
+	for _, message := range missed {
+		writeServerEvent(c.Writer, "", message.Seq, message.Data)
+	}
+	c.Writer.Flush()
+
+	ping := time.NewTicker(eventStreamPingPeriod)
+	defer ping.Stop()
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case message, open := <-client.Messages():
+			// The hub closes the queue of streams that do not keep up, the client reconnects and resumes
+			if !open {
+				return
+			}
+			writeServerEvent(c.Writer, "", message.Seq, message.Data)
+		case <-ping.C:
+			io.WriteString(c.Writer, ": ping\n\n")
+		}
+		c.Writer.Flush()
+	}
+}
+
+// writeServerEvent writes one server-sent event, without a name it is delivered as a message
+func writeServerEvent(w io.Writer, name string, seq uint64, data []byte) {
+	if name != "" {
+		fmt.Fprintf(w, "event: %s\n", name)
+	}
+	if seq != 0 {
+		fmt.Fprintf(w, "id: %d\n", seq)
+	}
+	fmt.Fprintf(w, "data: %s\n\n", data)
+}
@@ -0,0 +1,210 @@
+// handlers/league.handler.go
+package handlers
+
+import (
+	"errors"
+	"go-orm-template/models"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+func AddLeague(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found"})
+		return
+	}
+
+	var payload struct {
+		Name string `json:"name"`
+		Mode string `json:"mode"`
+	}
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	league := &models.League{
+		Name:    payload.Name,
+		Mode:    payload.Mode,
+		OwnerID: userID.(uint),
+	}
+	err := models.AddLeague(league)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "Successfully created league", "id": league.ID, "invite_code": league.InviteCode})
+}
+
+func GetAllLeagues(c *gin.Context) {
+	leagues, err := models.GetAllLeagues()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, leagues)
+}
+
+func GetMyLeagues(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found"})
+		return
+	}
+
+	season, ok := getSeason(c)
+	if !ok {
+		return
+	}
+
+	leagues, err := models.GetLeaguesByUserID(userID.(uint), season.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, leagues)
+}
+
+func JoinLeague(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found"})
+		return
+	}
+
+	var payload struct {
+		InviteCode string `json:"invite_code" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	league, err := models.JoinLeagueByCode(payload.InviteCode, userID.(uint))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Successfully joined league", "id": league.ID, "name": league.Name})
+}
+
+// getMemberLeague loads the league from the path and checks that the requesting user is a member
+func getMemberLeague(c *gin.Context) (*models.League, uint, bool) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found"})
+		return nil, 0, false
+	}
+
+	league, err := models.GetLeagueByID(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil, 0, false
+	}
+
+	if err := models.CheckLeagueMember(league, userID.(uint)); err != nil {
+		respondLeagueError(c, err)
+		return nil, 0, false
+	}
+	return league, userID.(uint), true
+}
+
+func respondLeagueError(c *gin.Context, err error) {
+	if errors.Is(err, models.ErrNotLeagueMember) || errors.Is(err, models.ErrNotLeagueAdmin) {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+}
+
+func GetLeague(c *gin.Context) {
+	league, _, ok := getMemberLeague(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, league)
+}
+
+func GetLeagueLeaderBoard(c *gin.Context) {
+	league, _, ok := getMemberLeague(c)
+	if !ok {
+		return
+	}
+
+	leaderBoard, err := models.GetLeagueLeaderBoard(league)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, leaderBoard)
+}
+
+func GetLeagueFixtures(c *gin.Context) {
+	league, _, ok := getMemberLeague(c)
+	if !ok {
+		return
+	}
+
+	round := 0
+	if roundParam := c.Query("round"); roundParam != "" {
+		var err error
+		round, err = strconv.Atoi(roundParam)
+		if err != nil || round < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "round must be a positive number"})
+			return
+		}
+	}
+
+	fixtures, err := models.GetLeagueFixtures(league, round)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, fixtures)
+}
+
+func GetLeagueStandings(c *gin.Context) {
+	league, _, ok := getMemberLeague(c)
+	if !ok {
+		return
+	}
+
+	standings, err := models.GetLeagueStandings(league)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, standings)
+}
+
+func RemoveLeagueMember(c *gin.Context) {
+	league, requesterID, ok := getMemberLeague(c)
+	if !ok {
+		return
+	}
+
+	memberID, err := strconv.ParseUint(c.Param("user_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "user_id must be a number"})
+		return
+	}
+
+	err = models.RemoveLeagueMember(league, requesterID, uint(memberID))
+	if err != nil {
+		respondLeagueError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Successfully removed league member"})
+}
@@ -0,0 +1,166 @@
+// handlers/match.handler.go
+package handlers
+
+import (
+	"go-orm-template/models"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+func AddMatch(c *gin.Context) {
+	var match models.Match
+	if err := c.ShouldBindJSON(&match); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err := models.AddMatch(&match)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "Successfully added match", "id": match.ID})
+}
+
+func GetAllMatches(c *gin.Context) {
+	season, ok := getSeason(c)
+	if !ok {
+		return
+	}
+
+	matches, err := models.GetAllMatches(season.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, matches)
+}
+
+func GetMatchByID(c *gin.Context) {
+	id := c.Param("id")
+	match, err := models.GetMatchByID(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, match)
+}
+
+func UpdateMatch(c *gin.Context) {
+	id := c.Param("id")
+	match, err := models.GetMatchByID(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := c.ShouldBindJSON(&match); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	match.Innings = nil
+
+	err = models.UpdateMatchByID(match)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Successfully updated match"})
+}
+
+func GetMatchStats(c *gin.Context) {
+	id := c.Param("id")
+	stats, err := models.GetMatchStatsByMatchID(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, stats)
+}
+
+func AddInnings(c *gin.Context) {
+	id := c.Param("id")
+	var innings models.Innings
+	if err := c.ShouldBindJSON(&innings); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err := models.AddInningsToMatch(id, &innings)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "Successfully added innings", "id": innings.ID})
+}
+
+func RecordDelivery(c *gin.Context) {
+	id := c.Param("id")
+	var delivery models.Delivery
+	if err := c.ShouldBindJSON(&delivery); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	changes, err := models.RecordDelivery(id, &delivery)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	notifyPlayerChanges(changes)
+
+	c.JSON(http.StatusCreated, gin.H{"message": "Successfully recorded delivery", "delivery": delivery})
+}
+
+func UndoLastDelivery(c *gin.Context) {
+	id := c.Param("id")
+	delivery, changes, err := models.UndoLastDelivery(id)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	notifyPlayerChanges(changes)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Successfully removed delivery", "delivery": delivery})
+}
+
+func GetPlayerDeliveries(c *gin.Context) {
+	id := c.Param("id")
+	deliveries, err := models.GetDeliveriesByPlayerID(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, deliveries)
+}
+
+func GetFixtures(c *gin.Context) {
+	season, ok := getSeason(c)
+	if !ok {
+		return
+	}
+
+	fixtures, err := models.GetFixtures(season.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, fixtures)
+}
+
+func GetRoundDeadline(c *gin.Context) {
+	deadline, err := models.GetCurrentRoundDeadline()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, deadline)
+}
@@ -2,49 +2,14 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
 	"go-orm-template/models"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
-	"github.com/gorilla/websocket"
 )
 
-// Global variable to hold the WebSocket connection
-var wsConnection *websocket.Conn
-
-func PlayerWebSocket(c *gin.Context) {
-	// Upgrade the connection to a websocket connection
-	ws, err := models.Upgrader.Upgrade(c.Writer, c.Request, nil)
-	if err != nil {
-		fmt.Println(err)
-		return
-	}
-	defer ws.Close()
-
-	// Store the WebSocket connection
-	wsConnection = ws
-
-	for {
-		// Read message from browser
-		msgType, msg, err := ws.ReadMessage()
-		if err != nil {
-			fmt.Println(err)
-			break
-		}
-
-		// Print the message to the console
-		fmt.Printf("Received: %s\n", msg)
-
-		// Write message back to the browser
-		err = ws.WriteMessage(msgType, msg)
-		if err != nil {
-			fmt.Println(err)
-			break
-		}
-	}
-}
-
 func AddPlayer(c *gin.Context) {
 	var player models.Player
 	var err error
@@ -60,7 +25,7 @@ func AddPlayer(c *gin.Context) {
 		return
 	}
 
-	NotifySubscribers("player", "add", &player.ID)
+	NotifyChange("player", "add", &player.ID, nil, &player)
 
 	c.JSON(http.StatusCreated, gin.H{"message": "Successfully added player"})
 }
@@ -83,29 +48,16 @@ func GetPlayerByIDForUser(c *gin.Context) {
 		return
 	}
 
-	playerForUser := models.PlayerForUser{
-		ID:                player.ID,
-		Name:              player.Name,
-		University:        player.University,
-		Category:          player.Category,
-		TotalRuns:         player.TotalRuns,
-		BallsFaced:        player.BallsFaced,
-		InningsPlayed:     player.InningsPlayed,
-		Wickets:           player.Wickets,
-		OversBowled:       player.OversBowled,
-		RunsConceded:      player.RunsConceded,
-		Value:             player.Value,
-		BattingStrikeRate: player.BattingStrikeRate,
-		BattingAverage:    player.BattingAverage,
-		BowlingStrikeRate: player.BowlingStrikeRate,
-		EconomyRate:       player.EconomyRate,
-	}
-
-	c.JSON(http.StatusOK, playerForUser)
+	c.JSON(http.StatusOK, models.NewPlayerForUser(player))
 }
 
 func GetAllPlayers(c *gin.Context) {
-	players, err := models.GetAllPlayers()
+	season, ok := getSeason(c)
+	if !ok {
+		return
+	}
+
+	players, err := models.GetAllPlayers(season.ID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -115,7 +67,13 @@ func GetAllPlayers(c *gin.Context) {
 }
 
 func GetAllPlayersByFilter(c *gin.Context) {
+	season, ok := getSeason(c)
+	if !ok {
+		return
+	}
+
 	filters := make(map[string]interface{})
+	filters["season_id"] = season.ID
 	if university := c.Query("university"); university != "" {
 		filters["university"] = university
 	}
@@ -149,23 +107,7 @@ func GetAllPlayersByFilter(c *gin.Context) {
 	if exists && role == "user" {
 		var playersForUser []models.PlayerForUser
 		for _, player := range players {
-			playersForUser = append(playersForUser, models.PlayerForUser{
-				ID:                player.ID,
-				Name:              player.Name,
-				University:        player.University,
-				Category:          player.Category,
-				TotalRuns:         player.TotalRuns,
-				BallsFaced:        player.BallsFaced,
-				InningsPlayed:     player.InningsPlayed,
-				Wickets:           player.Wickets,
-				OversBowled:       player.OversBowled,
-				RunsConceded:      player.RunsConceded,
-				Value:             player.Value,
-				BattingStrikeRate: player.BattingStrikeRate,
-				BattingAverage:    player.BattingAverage,
-				BowlingStrikeRate: player.BowlingStrikeRate,
-				EconomyRate:       player.EconomyRate,
-			})
+			playersForUser = append(playersForUser, models.NewPlayerForUser(player))
 		}
 		c.JSON(http.StatusOK, playersForUser)
 		return
@@ -177,47 +119,112 @@ func GetAllPlayersByFilter(c *gin.Context) {
 }
 
 func UpdatePlayer(c *gin.Context) {
-	id := c.Param("id")
-	player, err := models.GetPlayerByID(id)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
-
-	if err := c.ShouldBindJSON(&player); err != nil {
+	// Only the details can be changed, the totals are derived from the recorded deliveries and scorecards
+	// and the season is the stored player's
+	var details models.PlayerDetails
+	if err := c.ShouldBindJSON(&details); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	err = models.UpdatePlayerByID(player)
+	old, player, err := models.UpdatePlayerByID(c.Param("id"), details)
 	if err != nil {
+		if errors.Is(err, models.ErrSeasonReadOnly) {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	NotifySubscribers("player", "update", &player.ID)
+	NotifyChange("player", "update", &player.ID, old, player)
 
 	c.JSON(http.StatusOK, gin.H{"message": "Successfully updated player"})
 }
 
 func DeletePlayer(c *gin.Context) {
 	id := c.Param("id")
-	err := models.DeletePlayerByID(id)
+	player, err := models.DeletePlayerByID(id)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	NotifySubscribers("player", "delete", nil)
+	NotifyChange("player", "delete", &player.ID, player, nil)
 
 	c.JSON(http.StatusOK, gin.H{"message": "Successfully deleted player"})
 }
 
+func GetPlayerHistory(c *gin.Context) {
+	id := c.Param("id")
+	_, err := models.GetPlayerByID(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	history, err := models.GetPlayerStatHistory(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	role, exists := c.Get("role")
+
+	if exists && role == "user" {
+		historyForUser := []models.PlayerStatSnapshotForUser{}
+		for _, snapshot := range history {
+			historyForUser = append(historyForUser, models.PlayerStatSnapshotForUser{
+				Value:     snapshot.Value,
+				CreatedAt: snapshot.CreatedAt,
+			})
+		}
+		c.JSON(http.StatusOK, historyForUser)
+		return
+	}
+
+	c.JSON(http.StatusOK, history)
+}
+
 func GetTournamentSummary(c *gin.Context) {
-	summary, err := models.GetTournamentSummary()
+	season, ok := getSeason(c)
+	if !ok {
+		return
+	}
+
+	summary, err := models.GetTournamentSummary(season.ID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 	c.JSON(http.StatusOK, summary)
 }
+
+// RunMarket moves the active season's player prices by transfer demand without waiting for the scheduled run
+func RunMarket(c *gin.Context) {
+	run, err := models.RunMarket()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	models.QueuePlayersRecompute(run.PlayerIDs...)
+
+	NotifySubscribers("player", "update", nil)
+
+	c.JSON(http.StatusOK, gin.H{"message": fmt.Sprintf("Successfully changed the price of %d players", run.PlayersChanged), "run": run})
+}
+
+func GetMarketRuns(c *gin.Context) {
+	season, ok := getSeason(c)
+	if !ok {
+		return
+	}
+
+	runs, err := models.GetMarketRuns(season.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, runs)
+}
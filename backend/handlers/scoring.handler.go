@@ -0,0 +1,120 @@
+// handlers/scoring.handler.go
+package handlers
+
+import (
+	"go-orm-template/models"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+func AddScoringRuleSet(c *gin.Context) {
+	var rules models.ScoringRuleSet
+	if err := c.ShouldBindJSON(&rules); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err := models.AddScoringRuleSet(&rules)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "Successfully added scoring rule set", "id": rules.ID, "version": rules.Version})
+}
+
+func GetAllScoringRuleSets(c *gin.Context) {
+	season, ok := getSeason(c)
+	if !ok {
+		return
+	}
+
+	rules, err := models.GetAllScoringRuleSets(season.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, rules)
+}
+
+func GetActiveScoringRuleSet(c *gin.Context) {
+	c.JSON(http.StatusOK, models.GetActiveScoringRules())
+}
+
+func GetScoringRuleSetByID(c *gin.Context) {
+	id := c.Param("id")
+	rules, err := models.GetScoringRuleSetByID(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, rules)
+}
+
+func UpdateScoringRuleSet(c *gin.Context) {
+	id := c.Param("id")
+	rules, err := models.GetScoringRuleSetByID(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	// Only the weights can be changed, the identity and activation of the rule set are kept
+	stored := *rules
+
+	if err := c.ShouldBindJSON(&rules); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	rules.GormModel = stored.GormModel
+	rules.SeasonID = stored.SeasonID
+	rules.ActivatedAt = stored.ActivatedAt
+	rules.Version = stored.Version
+
+	err = models.UpdateScoringRuleSet(rules)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Successfully updated scoring rule set"})
+}
+
+func DeleteScoringRuleSet(c *gin.Context) {
+	id := c.Param("id")
+	err := models.DeleteScoringRuleSetByID(id)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Successfully deleted scoring rule set"})
+}
+
+func PreviewScoringRuleSet(c *gin.Context) {
+	id := c.Param("id")
+	rules, err := models.GetScoringRuleSetByID(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	preview, err := models.PreviewScoringRuleSet(rules)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, preview)
+}
+
+func ActivateScoringRuleSet(c *gin.Context) {
+	id := c.Param("id")
+	rules, err := models.ActivateScoringRuleSet(id)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	NotifySubscribers("player", "update", nil)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Successfully activated scoring rule set", "version": rules.Version})
+}
@@ -0,0 +1,109 @@
+// handlers/season.handler.go
+package handlers
+
+import (
+	"go-orm-template/models"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// getSeason resolves the season from the "season" query parameter, defaulting to the active season
+func getSeason(c *gin.Context) (*models.Season, bool) {
+	season, err := models.ResolveSeason(c.Query("season"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "season not found: " + err.Error()})
+		return nil, false
+	}
+	return season, true
+}
+
+func AddSeason(c *gin.Context) {
+	var season models.Season
+	if err := c.ShouldBindJSON(&season); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err := models.AddSeason(&season)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "Successfully added season", "id": season.ID})
+}
+
+func GetAllSeasons(c *gin.Context) {
+	seasons, err := models.GetAllSeasons()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, seasons)
+}
+
+func GetActiveSeason(c *gin.Context) {
+	season, err := models.GetActiveSeason()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, season)
+}
+
+func GetSeasonByID(c *gin.Context) {
+	id := c.Param("id")
+	season, err := models.GetSeasonByID(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, season)
+}
+
+func UpdateSeason(c *gin.Context) {
+	id := c.Param("id")
+	season, err := models.GetSeasonByID(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	var payload struct {
+		Name   string `json:"name"`
+		Budget int    `json:"budget"`
+	}
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if payload.Name != "" {
+		season.Name = payload.Name
+	}
+	if payload.Budget != 0 {
+		season.Budget = payload.Budget
+	}
+
+	err = models.UpdateSeason(season)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Successfully updated season"})
+}
+
+func ActivateSeason(c *gin.Context) {
+	id := c.Param("id")
+	season, err := models.ActivateSeason(id)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	NotifySubscribers("season", "update", &season.ID)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Successfully activated season", "id": season.ID})
+}
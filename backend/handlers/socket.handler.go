@@ -2,20 +2,210 @@ package handlers
 
 import (
 	"fmt"
+	"go-orm-template/auth"
 	"go-orm-template/models"
+	"net/http"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
 )
 
-func NotifySubscribers(entity string, action string, id *uint) {
-	models.UpdateAllTeamsPointsAndValue()
-	// Notify via WebSocket
-	if wsConnection != nil {
-		uniqueID := uuid.New().String()
-		err := wsConnection.WriteJSON(gin.H{"entity": "entity", "action": action, "id": id, "uid": uniqueID})
+// PlayerWebSocket connects an authenticated client to the hub. Clients authenticate with a stream ticket
+// as the ticket query parameter, the API's JWT in the Authorization header or the first message
+//
+//	{"action": "auth", "token": "<jwt>"}
+//
+// Clients receive every change until they send
+//
+//	{"action": "subscribe", "topics": ["player:12", "team:3", "leaderboard", "summary"]}
+//
+// after which they only receive changes to their topics; "unsubscribe" removes topics again. A client
+// that reconnects catches up on the changes it missed with the last sequence number it saw
+//
+//	{"action": "resume", "seq": 1718972400000042}
+func PlayerWebSocket(c *gin.Context) {
+	claims, err := requestClaims(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Upgrade the connection to a websocket connection, rejecting origins that are not allowed
+	ws, err := models.Upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	if claims == nil {
+		claims, err = readSocketToken(ws)
 		if err != nil {
-			fmt.Println("Error sending WebSocket message:", err)
+			ws.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.ClosePolicyViolation, err.Error()), time.Now().Add(time.Second))
+			ws.Close()
+			return
 		}
 	}
+
+	client := models.SocketHub.Register(ws, claims.UserID, claims.Role)
+	client.Send(gin.H{"action": "authenticated", "user_id": claims.UserID, "role": claims.Role})
+	client.ReadMessages(func(message models.SocketMessage) {
+		switch message.Action {
+		case "subscribe":
+			if err := client.Subscribe(message.Topics); err != nil {
+				client.Send(gin.H{"error": err.Error()})
+				return
+			}
+		case "unsubscribe":
+			client.Unsubscribe(message.Topics)
+		case "resume":
+			if err := client.Resume(message.Seq); err != nil {
+				client.Send(gin.H{"error": err.Error()})
+			}
+			return
+		case "auth":
+			client.Send(gin.H{"error": "already authenticated"})
+			return
+		default:
+			client.Send(gin.H{"error": fmt.Sprintf("unknown action %q, use subscribe, unsubscribe or resume", message.Action)})
+			return
+		}
+		client.Send(gin.H{"action": "subscriptions", "topics": client.Topics()})
+	})
+}
+
+// IssueStreamTicket returns a single-use ticket for opening the websocket or the event stream. Browsers
+// cannot send headers with either, and a JWT in the URL would end up in access logs.
+func IssueStreamTicket(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	role, _ := c.Get("role")
+	ticket, err := models.IssueStreamTicket(userID.(uint), role.(string))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, ticket)
+}
+
+// requestClaims returns the claims of the ticket query parameter or of the token in the Authorization
+// header, or nil when the request has neither
+func requestClaims(c *gin.Context) (*auth.Claims, error) {
+	if key := c.Query("ticket"); key != "" {
+		ticket, err := models.RedeemStreamTicket(key)
+		if err != nil {
+			return nil, err
+		}
+		return &auth.Claims{UserID: ticket.UserID, Role: ticket.Role}, nil
+	}
+	token := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if token == "" {
+		return nil, nil
+	}
+	claims, err := auth.ParseToken(token)
+	if err != nil {
+		return nil, auth.ErrInvalidToken
+	}
+	return claims, nil
+}
+
+// readSocketToken waits for the client's auth message and returns the claims of its token
+func readSocketToken(ws *websocket.Conn) (*auth.Claims, error) {
+	ws.SetReadDeadline(time.Now().Add(models.SocketAuthWait))
+
+	var message models.SocketMessage
+	if err := ws.ReadJSON(&message); err != nil || message.Action != "auth" || message.Token == "" {
+		return nil, fmt.Errorf("authentication required")
+	}
+	claims, err := auth.ParseToken(message.Token)
+	if err != nil {
+		return nil, auth.ErrInvalidToken
+	}
+	return claims, nil
+}
+
+// notificationTopics returns the topics a change is published to. Player changes also move the
+// tournament summary and the leaderboard, team changes the leaderboard.
+func notificationTopics(entity string, id *uint) []string {
+	topics := []string{entity}
+	if id != nil {
+		topics = append(topics, fmt.Sprintf("%s:%d", entity, *id))
+	} else {
+		topics = append(topics, entity+":*")
+	}
+
+	switch entity {
+	case models.TopicPlayer:
+		topics = append(topics, models.TopicSummary, models.TopicLeaderboard)
+	case models.TopicTeam:
+		topics = append(topics, models.TopicLeaderboard)
+	}
+	return topics
+}
+
+// userView returns the part of an entity that users may see: players without their points and teams
+// without their squad, captains and budget. Every user receives the events of every team.
+func userView(value interface{}) interface{} {
+	switch entity := value.(type) {
+	case *models.Player:
+		if entity == nil {
+			return nil
+		}
+		return models.NewPlayerForUser(entity)
+	case *models.Team:
+		if entity == nil {
+			return nil
+		}
+		return models.NewTeamForUser(entity)
+	case *models.TeamPlayersView:
+		if entity == nil {
+			return nil
+		}
+		return entity.ForUser()
+	}
+	return value
+}
+
+// NotifySubscribers publishes a change without field details, for changes to many entities at once or
+// where the previous version is not known. Clients refetch what they show of the entity.
+func NotifySubscribers(entity string, action string, id *uint) {
+	NotifyChange(entity, action, id, nil, nil)
+}
+
+// NotifyChange publishes a change to an entity with the fields that differ between its old and new
+// version. Old is nil for added entities and new is nil for deleted ones.
+func NotifyChange(entity string, action string, id *uint, old interface{}, new interface{}) {
+	adminChanges, err := models.DiffFields(old, new)
+	if err != nil {
+		fmt.Println("Error comparing changes:", err)
+		return
+	}
+	userChanges, err := models.DiffFields(userView(old), userView(new))
+	if err != nil {
+		fmt.Println("Error comparing changes:", err)
+		return
+	}
+
+	adminEvent := &models.ChangeEvent{
+		Time:    time.Now().UTC(),
+		Entity:  entity,
+		Action:  action,
+		ID:      id,
+		Changes: adminChanges,
+	}
+	userEvent := *adminEvent
+	userEvent.Changes = userChanges
+
+	// Notify via WebSocket
+	err = models.SocketHub.Publish(notificationTopics(entity, id), adminEvent, &userEvent)
+	if err != nil {
+		fmt.Println("Error sending WebSocket message:", err)
+	}
+}
+
+// notifyPlayerChanges publishes the players changed by a model function
+func notifyPlayerChanges(changes []*models.PlayerChange) {
+	for _, change := range changes {
+		NotifyChange(models.TopicPlayer, "update", &change.New.ID, change.Old, change.New)
+	}
 }
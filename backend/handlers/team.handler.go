@@ -2,9 +2,11 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
 	"go-orm-template/models"
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 )
@@ -38,7 +40,12 @@ func GetTeamByID(c *gin.Context) {
 }
 
 func GetAllTeams(c *gin.Context) {
-	teams, err := models.GetAllTeams()
+	season, ok := getSeason(c)
+	if !ok {
+		return
+	}
+
+	teams, err := models.GetAllTeams(season.ID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -75,18 +82,25 @@ func UpdateTeam(c *gin.Context) {
 		return
 	}
 
-	if err := c.ShouldBindJSON(&team); err != nil {
+	// Only the name can be changed, the squad and the totals derived from it belong to the locked
+	// assignment and recalculation transactions
+	var payload struct {
+		Name string `json:"name"`
+	}
+	if err := c.ShouldBindJSON(&payload); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	old := *team
+	team.Name = payload.Name
 
-	err = models.UpdateTeamByID(team)
+	err = models.SaveTeamName(team)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondTeamChangeError(c, err)
 		return
 	}
 
-	NotifySubscribers("team", "update", &team.ID)
+	NotifyChange("team", "update", &team.ID, &old, team)
 	c.JSON(http.StatusOK, gin.H{"message": "Successfully updated team"})
 }
 
@@ -94,7 +108,7 @@ func DeleteTeam(c *gin.Context) {
 	id := c.Param("id")
 	err := models.DeleteTeamByID(id)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondTeamChangeError(c, err)
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"message": "Successfully deleted team"})
@@ -107,22 +121,32 @@ func UpdateMyTeam(c *gin.Context) {
 		return
 	}
 
-	team := &models.Team{}
+	season, err := models.GetActiveSeason()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
 
-	team, err := models.GetTeamByUserID(fmt.Sprintf("%v", id))
+	team, err := models.GetTeamByUserID(fmt.Sprintf("%v", id), season.ID)
 
 	if err != nil {
 		team = &models.Team{
-			UserID: id.(uint),
+			SeasonID: season.ID,
+			UserID:   id.(uint),
 		}
 	}
 
-	if err := c.ShouldBindJSON(&team); err != nil {
+	// Only the name can be changed, points, value and transfers are managed by the server
+	var payload struct {
+		Name string `json:"name"`
+	}
+	if err := c.ShouldBindJSON(&payload); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	team.Name = payload.Name
 
-	err = models.UpdateTeamByID(team)
+	err = models.SaveTeamName(team)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -134,7 +158,8 @@ func UpdateMyTeam(c *gin.Context) {
 
 func AssingPlayersToTeamByUserID(c *gin.Context) {
 	var payload struct {
-		PlayerIDs []uint `json:"player_ids"`
+		PlayerIDs      []uint `json:"player_ids"`
+		BenchPlayerIDs []uint `json:"bench_player_ids"`
 	}
 
 	if err := c.ShouldBindJSON(&payload); err != nil {
@@ -149,19 +174,125 @@ func AssingPlayersToTeamByUserID(c *gin.Context) {
 	}
 
 	teamPlayers := models.TeamPlayers{
-		UserID:    userID.(uint),
-		PlayerIDs: payload.PlayerIDs,
+		UserID:         userID.(uint),
+		PlayerIDs:      payload.PlayerIDs,
+		BenchPlayerIDs: payload.BenchPlayerIDs,
+	}
+
+	team, err := models.AssignPlayersToTeamByUserID(teamPlayers)
+	if err != nil {
+		respondTeamChangeError(c, err)
+		return
+	}
+
+	NotifyChange("team", "update", &team.ID, team.Previous, team)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Successfully added players to team", "team": team})
+}
+
+// AssignPlayersToTeamByID lets admins change any squad, including after the round deadline
+func AssignPlayersToTeamByID(c *gin.Context) {
+	var payload struct {
+		PlayerIDs      []uint `json:"player_ids"`
+		BenchPlayerIDs []uint `json:"bench_player_ids"`
+	}
+
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	id := c.Param("id")
+	team, err := models.GetTeamByID(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if err := models.CheckSeasonWritable(team.SeasonID); err != nil {
+		respondTeamChangeError(c, err)
+		return
+	}
+
+	teamPlayers := models.TeamPlayers{
+		UserID:         team.UserID,
+		PlayerIDs:      payload.PlayerIDs,
+		BenchPlayerIDs: payload.BenchPlayerIDs,
+		Override:       true,
 	}
 
-	err := models.AssignPlayersToTeamByUserID(teamPlayers)
+	teamPlayersView, err := models.AssignPlayersToTeamByUserID(teamPlayers)
 	if err != nil {
+		respondTeamChangeError(c, err)
+		return
+	}
+
+	NotifyChange("team", "update", &team.ID, teamPlayersView.Previous, teamPlayersView)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Successfully added players to team", "team": teamPlayersView})
+}
+
+func SetMyTeamCaptains(c *gin.Context) {
+	var payload struct {
+		CaptainID     *uint `json:"captain_id"`
+		ViceCaptainID *uint `json:"vice_captain_id"`
+	}
+
+	if err := c.ShouldBindJSON(&payload); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	NotifySubscribers("team", "update", nil)
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found"})
+		return
+	}
+
+	teamCaptains := models.TeamCaptains{
+		UserID:        userID.(uint),
+		CaptainID:     payload.CaptainID,
+		ViceCaptainID: payload.ViceCaptainID,
+	}
+
+	team, err := models.SetTeamCaptains(teamCaptains)
+	if err != nil {
+		respondTeamChangeError(c, err)
+		return
+	}
+
+	NotifyChange("team", "update", &team.ID, team.Previous, team)
 
-	c.JSON(http.StatusOK, gin.H{"message": "Successfully added players to team"})
+	c.JSON(http.StatusOK, gin.H{"message": "Successfully updated captains", "team": team})
+}
+
+// respondTeamChangeError reports a rejected squad change, using 423 Locked after the round deadline
+func respondTeamChangeError(c *gin.Context, err error) {
+	var lockedErr *models.TeamLockedError
+	if errors.As(err, &lockedErr) {
+		c.JSON(http.StatusLocked, gin.H{
+			"error":    err.Error(),
+			"round":    lockedErr.Round,
+			"deadline": lockedErr.Deadline,
+		})
+		return
+	}
+	var compositionErr *models.SquadCompositionError
+	if errors.As(err, &compositionErr) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   err.Error(),
+			"details": compositionErr.Violations,
+		})
+		return
+	}
+	if errors.Is(err, models.ErrSeasonReadOnly) {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+}
+
+func GetSquadRules(c *gin.Context) {
+	c.JSON(http.StatusOK, models.GetSquadRules())
 }
 
 func GetMyTeam(c *gin.Context) {
@@ -171,7 +302,12 @@ func GetMyTeam(c *gin.Context) {
 		return
 	}
 
-	teamPlayersView, err := models.GetMyTeamModel(userID.(uint))
+	season, ok := getSeason(c)
+	if !ok {
+		return
+	}
+
+	teamPlayersView, err := models.GetMyTeamModel(userID.(uint), season.ID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -181,7 +317,24 @@ func GetMyTeam(c *gin.Context) {
 }
 
 func GetTeamLeaderBoard(c *gin.Context) {
-	leaderBoard, err := models.GetTeamLeaderBoard()
+	var leaderBoard []*models.LeaderboardEntry
+	var err error
+
+	season, ok := getSeason(c)
+	if !ok {
+		return
+	}
+
+	if roundParam := c.Query("round"); roundParam != "" {
+		round, convErr := strconv.Atoi(roundParam)
+		if convErr != nil || round < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "round must be a positive number"})
+			return
+		}
+		leaderBoard, err = models.GetRoundLeaderBoard(season.ID, round)
+	} else {
+		leaderBoard, err = models.GetLiveLeaderBoard(season.ID)
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -189,3 +342,121 @@ func GetTeamLeaderBoard(c *gin.Context) {
 
 	c.JSON(http.StatusOK, leaderBoard)
 }
+
+func SnapshotRound(c *gin.Context) {
+	round := 0
+	if roundParam := c.Query("round"); roundParam != "" {
+		var err error
+		round, err = strconv.Atoi(roundParam)
+		if err != nil || round < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "round must be a positive number"})
+			return
+		}
+	}
+
+	round, err := models.SnapshotRound(round)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	NotifySubscribers("team", "snapshot", nil)
+
+	c.JSON(http.StatusOK, gin.H{"message": fmt.Sprintf("Successfully snapshotted round %d", round), "round": round})
+}
+
+// RecomputeTeams queues the recalculation of every team's points and value in the background
+func RecomputeTeams(c *gin.Context) {
+	models.QueueAllTeamsRecompute()
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "Successfully queued team recalculation"})
+}
+
+// GetTeamRecomputeStatus reports the progress of the background team recalculation
+func GetTeamRecomputeStatus(c *gin.Context) {
+	c.JSON(http.StatusOK, models.GetTeamRecomputeStatus())
+}
+
+func GetTeamHistory(c *gin.Context) {
+	id := c.Param("id")
+	team, err := models.GetTeamByID(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	history, err := models.GetTeamHistory(team.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, history)
+}
+
+func GetMyTeamHistory(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found"})
+		return
+	}
+
+	season, ok := getSeason(c)
+	if !ok {
+		return
+	}
+
+	team, err := models.GetTeamByUserID(fmt.Sprintf("%v", userID), season.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	history, err := models.GetTeamHistory(team.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, history)
+}
+
+func GetTeamTransfers(c *gin.Context) {
+	id := c.Param("id")
+	team, err := models.GetTeamByID(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	history, err := models.GetTransferHistory(team)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, history)
+}
+
+func GetMyTransfers(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID not found"})
+		return
+	}
+
+	season, ok := getSeason(c)
+	if !ok {
+		return
+	}
+
+	team, err := models.GetTeamByUserID(fmt.Sprintf("%v", userID), season.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	history, err := models.GetTransferHistory(team)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, history)
+}
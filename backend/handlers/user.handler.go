@@ -99,21 +99,31 @@ func GetMyProfile(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	// Budgets are set per season, the profile shows the active season's
+	season, err := models.GetActiveSeason()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
 	myProfile := models.MyProfile{
 		Name:            user.Name,
 		Username:        user.Username,
-		Budget:          user.Budget,
-		AvailableBudget: user.Budget,
+		Budget:          season.Budget,
+		AvailableBudget: season.Budget,
 	}
 
-	team, err := models.GetTeamByUserID(idStr)
+	team, err := models.GetTeamByUserID(idStr, season.ID)
 	if err != nil {
 		c.JSON(http.StatusOK, myProfile)
 		return
 	}
 
 	myProfile.TeamName = team.Name
-	myProfile.AvailableBudget = user.Budget - team.Value
+	myProfile.AvailableBudget, err = models.GetAvailableBudget(team, season.Budget)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
 
 	c.JSON(http.StatusOK, myProfile)
 }
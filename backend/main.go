@@ -2,12 +2,14 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"log"
 	"os"
 
 	"go-orm-template/config"
 	"go-orm-template/db"
+	"go-orm-template/handlers"
 	"go-orm-template/models"
 	"go-orm-template/router"
 	"go-orm-template/scripts"
@@ -22,19 +24,103 @@ func main() {
 		log.Fatal("Database connection not established")
 	}
 
+	if err := models.SetupJoinTables(); err != nil {
+		log.Fatal(err)
+	}
+
 	if len(os.Args) > 1 {
 		switch os.Args[1] {
 		case "migrate":
+			fmt.Println("Migrating Season...")
+			db.ORM.AutoMigrate(&models.Season{})
 			fmt.Println("Migrating User...")
 			db.ORM.AutoMigrate(&models.User{})
+			fmt.Println("Migrating SquadPlayer...")
+			if err := models.MigrateSquadPlayers(); err != nil {
+				log.Fatal(err)
+			}
 			fmt.Println("Migrating Team...")
 			db.ORM.AutoMigrate(&models.Team{})
 			fmt.Println("Migrating Player...")
 			db.ORM.AutoMigrate(&models.Player{})
+			fmt.Println("Migrating PlayerStatSnapshot...")
+			db.ORM.AutoMigrate(&models.PlayerStatSnapshot{})
+			fmt.Println("Migrating Match...")
+			db.ORM.AutoMigrate(&models.Match{}, &models.Innings{}, &models.Delivery{})
+			fmt.Println("Migrating PlayerMatchStat...")
+			db.ORM.AutoMigrate(&models.PlayerMatchStat{})
+			fmt.Println("Migrating ScoringRuleSet...")
+			if err := models.MigrateScoringRuleSets(); err != nil {
+				log.Fatal(err)
+			}
+			fmt.Println("Migrating TeamRoundSnapshot...")
+			db.ORM.AutoMigrate(&models.TeamRoundSnapshot{}, &models.TeamRoundSubstitution{})
+			fmt.Println("Migrating League...")
+			db.ORM.AutoMigrate(&models.League{}, &models.LeagueMember{}, &models.LeagueFixture{})
+			fmt.Println("Migrating Transfer...")
+			db.ORM.AutoMigrate(&models.Transfer{})
+			fmt.Println("Migrating MarketRun...")
+			db.ORM.AutoMigrate(&models.MarketRun{})
+			fmt.Println("Migrating data into seasons...")
+			if err := models.MigrateSeasons(); err != nil {
+				log.Fatal(err)
+			}
 			fmt.Println("Migrating Finished.")
 			return
 		case "players":
-			scripts.ImportPlayersFromCSV()
+			flags := flag.NewFlagSet("players", flag.ExitOnError)
+			options := scripts.PlayerImportOptions{}
+			flags.StringVar(&options.Path, "file", "data/players.csv", "players CSV file")
+			flags.StringVar(&options.ReportPath, "report", "", "write the JSON import report to this file instead of printing it")
+			flags.BoolVar(&options.DryRun, "dry-run", false, "print the changes without applying them")
+			flags.BoolVar(&options.Prune, "prune", false, "delete players that are not in the file")
+			flags.BoolVar(&options.Reset, "reset", false, "delete the active season's players before importing")
+			flags.BoolVar(&options.Force, "force", false, "import the accepted rows even when some rows are rejected")
+			flags.Parse(os.Args[2:])
+			if err := scripts.ImportPlayersFromCSV(options); err != nil {
+				log.Fatal(err)
+			}
+			return
+		case "scorecards":
+			flags := flag.NewFlagSet("scorecards", flag.ExitOnError)
+			options := scripts.ScorecardImportOptions{}
+			flags.StringVar(&options.Path, "file", "data/scorecards.csv", "scorecards CSV file")
+			flags.StringVar(&options.ReportPath, "report", "", "write the JSON import report to this file instead of printing it")
+			flags.BoolVar(&options.Force, "force", false, "import the accepted rows even when some rows are rejected")
+			flags.Parse(os.Args[2:])
+			if err := scripts.ImportScorecardsFromCSV(options); err != nil {
+				log.Fatal(err)
+			}
+			return
+		case "snapshot":
+			flags := flag.NewFlagSet("snapshot", flag.ExitOnError)
+			round := flags.Int("round", 0, "round to snapshot, defaults to the current round")
+			flags.Parse(os.Args[2:])
+			snapshotted, err := models.SnapshotRound(*round)
+			if err != nil {
+				log.Fatal(err)
+			}
+			fmt.Printf("Snapshotted round %d\n", snapshotted)
+			return
+		case "repair":
+			flags := flag.NewFlagSet("repair", flag.ExitOnError)
+			options := scripts.RepairOptions{}
+			flags.StringVar(&options.ReportPath, "report", "", "write the JSON repair report to this file instead of printing it")
+			flags.BoolVar(&options.Fix, "fix", false, "repair the issues instead of only reporting them")
+			flags.Parse(os.Args[2:])
+			if err := scripts.RepairIntegrity(options); err != nil {
+				log.Fatal(err)
+			}
+			return
+		case "market":
+			run, err := models.RunMarket()
+			if err != nil {
+				log.Fatal(err)
+			}
+			fmt.Printf("Market run changed the price of %d players\n", run.PlayersChanged)
+			if err := models.RecomputeTeamsForPlayers(run.PlayerIDs, nil); err != nil {
+				log.Fatal(err)
+			}
 			return
 		case "users":
 			scripts.ImportDefaultUsers()
@@ -42,6 +128,10 @@ func main() {
 		}
 	}
 
+	models.StartMarketScheduler(func(run *models.MarketRun) {
+		handlers.NotifySubscribers("player", "update", nil)
+	})
+
 	r := router.NewRouter()
 	log.Fatal(r.Run(fmt.Sprintf(":%s", config.Port)))
 }
@@ -0,0 +1,142 @@
+// models/event.model.go
+package models
+
+import (
+	"encoding/json"
+	"go-orm-template/config"
+	"reflect"
+	"sort"
+	"time"
+)
+
+// ChangeEvent is the envelope of every change published to websocket and event stream clients,
+// documented in docs/websocket-events.md. Changes lists the fields that differ between the old and
+// new version of the entity; it is empty when many entities changed at once and clients have to
+// refetch.
+type ChangeEvent struct {
+	Seq     uint64                 `json:"seq"`
+	Time    time.Time              `json:"time"`
+	Entity  string                 `json:"entity"`
+	Action  string                 `json:"action"`
+	ID      *uint                  `json:"id"`
+	Changes map[string]FieldChange `json:"changes"`
+}
+
+// FieldChange is the value of a field before and after a change, Old is null for added entities and
+// New is null for deleted ones
+type FieldChange struct {
+	Old interface{} `json:"old"`
+	New interface{} `json:"new"`
+}
+
+// PlayerChange is a player before and after a change made inside a model function
+type PlayerChange struct {
+	Old *Player
+	New *Player
+}
+
+// loggedEvent is a published event as sent to admins and to users, with the topics it was published to
+type loggedEvent struct {
+	seq       uint64
+	topics    []string
+	adminData []byte
+	userData  []byte
+}
+
+// eventLog numbers the published events and keeps the latest config.EventLogSize of them, so clients
+// that lost their connection can catch up. Numbering starts from the server's start time in microseconds,
+// so a number seen before a restart is always older than the log and never mistaken for a newer event.
+// The hub's lock guards the log.
+type eventLog struct {
+	seq    uint64
+	events []*loggedEvent
+}
+
+func newEventLog() *eventLog {
+	return &eventLog{seq: uint64(time.Now().UnixMicro())}
+}
+
+// next returns the sequence number of the next event, one more than the last one. The number is only
+// used up once the event is added, so an event that fails to marshal leaves no gap in the log.
+func (l *eventLog) next() uint64 {
+	return l.seq + 1
+}
+
+// add logs the event numbered by next, dropping the oldest events beyond the log size
+func (l *eventLog) add(event *loggedEvent) {
+	l.seq = event.seq
+	l.events = append(l.events, event)
+	if excess := len(l.events) - config.EventLogSize; excess > 0 {
+		l.events = append([]*loggedEvent(nil), l.events[excess:]...)
+	}
+}
+
+// since returns the events after the sequence number, or false when events after it are no longer
+// kept or the number was never handed out
+func (l *eventLog) since(seq uint64) ([]*loggedEvent, bool) {
+	if seq > l.seq {
+		return nil, false
+	}
+	oldest := l.seq + 1
+	if len(l.events) > 0 {
+		oldest = l.events[0].seq
+	}
+	if seq+1 < oldest {
+		return nil, false
+	}
+	// Search for the first newer event instead of counting back from the last number, so the slice
+	// stays in bounds even if the numbers and the kept events ever disagree
+	first := sort.Search(len(l.events), func(i int) bool {
+		return l.events[i].seq > seq
+	})
+	return l.events[first:], true
+}
+
+// ignoredChangeFields are bookkeeping fields that change with every save
+var ignoredChangeFields = map[string]bool{"updated_at": true}
+
+// DiffFields returns the JSON fields that differ between the old and new version of an entity. Old is nil
+// for an added entity and new is nil for a deleted one. Nested objects and lists are compared as a whole.
+func DiffFields(old interface{}, new interface{}) (map[string]FieldChange, error) {
+	oldFields, err := jsonFields(old)
+	if err != nil {
+		return nil, err
+	}
+	newFields, err := jsonFields(new)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := make(map[string]FieldChange)
+	for name, value := range newFields {
+		if ignoredChangeFields[name] {
+			continue
+		}
+		if previous, ok := oldFields[name]; !ok || !reflect.DeepEqual(previous, value) {
+			changes[name] = FieldChange{Old: oldFields[name], New: value}
+		}
+	}
+	for name, previous := range oldFields {
+		if _, ok := newFields[name]; !ok && !ignoredChangeFields[name] {
+			changes[name] = FieldChange{Old: previous, New: nil}
+		}
+	}
+	return changes, nil
+}
+
+// jsonFields decodes the JSON object of the value into its fields, nil has no fields
+func jsonFields(value interface{}) (map[string]interface{}, error) {
+	fields := make(map[string]interface{})
+	if value == nil || reflect.ValueOf(value).Kind() == reflect.Ptr && reflect.ValueOf(value).IsNil() {
+		return fields, nil
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
@@ -0,0 +1,181 @@
+package models
+
+import (
+	"go-orm-template/config"
+	"testing"
+)
+
+// newTestEventLog returns a log of the events start+1 to start+published, keeping at most size of them
+func newTestEventLog(start uint64, published int, size int) *eventLog {
+	config.EventLogSize = size
+	log := &eventLog{seq: start}
+	for i := 0; i < published; i++ {
+		seq := log.next()
+		log.add(&loggedEvent{seq: seq, topics: []string{TopicPlayer}})
+	}
+	return log
+}
+
+func TestEventLogAdd(t *testing.T) {
+	tests := []struct {
+		name      string
+		published int
+		size      int
+		wantFirst uint64
+		wantCount int
+	}{
+		{"empty", 0, 3, 0, 0},
+		{"below size", 2, 3, 101, 2},
+		{"at size", 3, 3, 101, 3},
+		{"over size drops oldest", 5, 3, 103, 3},
+		{"size 0 keeps nothing", 4, 0, 0, 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			log := newTestEventLog(100, test.published, test.size)
+			if len(log.events) != test.wantCount {
+				t.Fatalf("kept %d events, want %d", len(log.events), test.wantCount)
+			}
+			if test.wantCount > 0 && log.events[0].seq != test.wantFirst {
+				t.Errorf("oldest event is %d, want %d", log.events[0].seq, test.wantFirst)
+			}
+			if log.seq != 100+uint64(test.published) {
+				t.Errorf("last sequence is %d, want %d", log.seq, 100+test.published)
+			}
+		})
+	}
+}
+
+func TestEventLogSince(t *testing.T) {
+	tests := []struct {
+		name      string
+		published int
+		size      int
+		since     uint64
+		wantSeqs  []uint64
+		wantFound bool
+	}{
+		{"nothing published", 0, 3, 100, nil, true},
+		{"before a restart", 0, 3, 99, nil, false},
+		{"all kept events", 3, 3, 100, []uint64{101, 102, 103}, true},
+		{"some events", 3, 3, 101, []uint64{102, 103}, true},
+		{"up to date", 3, 3, 103, nil, true},
+		{"from the future", 3, 3, 104, nil, false},
+		{"oldest kept event is next", 5, 3, 102, []uint64{103, 104, 105}, true},
+		{"events dropped", 5, 3, 101, nil, false},
+		{"size 0 up to date", 4, 0, 104, nil, true},
+		{"size 0 behind", 4, 0, 103, nil, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			log := newTestEventLog(100, test.published, test.size)
+			events, found := log.since(test.since)
+			if found != test.wantFound {
+				t.Fatalf("found is %v, want %v", found, test.wantFound)
+			}
+			if len(events) != len(test.wantSeqs) {
+				t.Fatalf("got %d events, want %v", len(events), test.wantSeqs)
+			}
+			for i, event := range events {
+				if event.seq != test.wantSeqs[i] {
+					t.Errorf("event %d is %d, want %d", i, event.seq, test.wantSeqs[i])
+				}
+			}
+		})
+	}
+}
+
+func TestMissedEvents(t *testing.T) {
+	events := []*loggedEvent{
+		{seq: 101, topics: []string{TopicPlayer, "player:1"}, adminData: []byte("admin 101"), userData: []byte("user 101")},
+		{seq: 102, topics: []string{TopicTeam, "team:1"}, adminData: []byte("admin 102"), userData: []byte("user 102")},
+		{seq: 103, topics: []string{TopicPlayer, "player:2"}, adminData: []byte("admin 103"), userData: []byte("user 103")},
+	}
+
+	tests := []struct {
+		name     string
+		role     string
+		joined   uint64
+		topics   []string
+		wantData []string
+	}{
+		{"joined after all events", "user", 103, nil, []string{"user 101", "user 102", "user 103"}},
+		{"events after joining were sent live", "user", 102, nil, []string{"user 101", "user 102"}},
+		{"joined before all events", "user", 100, nil, nil},
+		{"only subscribed topics", "user", 103, []string{"player:2"}, []string{"user 103"}},
+		{"admins get the admin event", "admin", 103, []string{TopicTeam}, []string{"admin 102"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client := &WebSocketClient{topics: make(map[string]bool), joined: test.joined, Role: test.role}
+			for _, topic := range test.topics {
+				client.topics[topic] = true
+			}
+
+			missed := client.missedEvents(events)
+			if len(missed) != len(test.wantData) {
+				t.Fatalf("got %d events, want %v", len(missed), test.wantData)
+			}
+			for i, message := range missed {
+				if string(message.Data) != test.wantData[i] {
+					t.Errorf("event %d is %q, want %q", i, message.Data, test.wantData[i])
+				}
+			}
+		})
+	}
+}
+
+func TestEventLogNextWithoutAdd(t *testing.T) {
+	log := newTestEventLog(100, 2, 3)
+	// An event that fails to marshal is never added, its number is handed out again
+	if seq := log.next(); seq != 103 {
+		t.Fatalf("next is %d, want 103", seq)
+	}
+	if seq := log.next(); seq != 103 {
+		t.Errorf("next after an event that was not added is %d, want 103", seq)
+	}
+
+	events, found := log.since(102)
+	if !found || len(events) != 0 {
+		t.Errorf("got %d events and found %v, want none and true", len(events), found)
+	}
+}
+
+func TestEventLogSinceOutOfStep(t *testing.T) {
+	// A log whose last number is ahead of its kept events must answer without indexing outside them
+	log := &eventLog{seq: 105, events: []*loggedEvent{{seq: 101}, {seq: 102}, {seq: 103}}}
+	config.EventLogSize = 3
+
+	tests := []struct {
+		name      string
+		since     uint64
+		wantSeqs  []uint64
+		wantFound bool
+	}{
+		{"all kept events", 100, []uint64{101, 102, 103}, true},
+		{"some events", 102, []uint64{103}, true},
+		{"after the kept events", 104, nil, true},
+		{"too old", 99, nil, false},
+		{"far too old", 1, nil, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			events, found := log.since(test.since)
+			if found != test.wantFound {
+				t.Fatalf("found is %v, want %v", found, test.wantFound)
+			}
+			if len(events) != len(test.wantSeqs) {
+				t.Fatalf("got %d events, want %v", len(events), test.wantSeqs)
+			}
+			for i, event := range events {
+				if event.seq != test.wantSeqs[i] {
+					t.Errorf("event %d is %d, want %d", i, event.seq, test.wantSeqs[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,77 @@
+// models/fixture.model.go
+package models
+
+import (
+	"fmt"
+	"go-orm-template/db"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// TeamLockedError is returned when a squad change is attempted after the round deadline
+type TeamLockedError struct {
+	Round    int
+	Deadline time.Time
+}
+
+func (e *TeamLockedError) Error() string {
+	return fmt.Sprintf("teams are locked for round %d since %s", e.Round, e.Deadline.Format(time.RFC3339))
+}
+
+type RoundDeadline struct {
+	Round    int        `json:"round"`
+	Deadline *time.Time `json:"deadline"` // Start of the round's first fixture, nil when none are scheduled
+	Locked   bool       `json:"locked"`
+}
+
+// GetFixtures returns the matches of the season without their scoring details
+func GetFixtures(seasonID uint) ([]*Match, error) {
+	var matches []*Match
+
+	result := db.ORM.Where("season_id = ?", seasonID).Order("round asc, start_time asc").Find(&matches)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return matches, nil
+}
+
+// GetCurrentRoundDeadline returns when squad changes close for the round in progress
+func GetCurrentRoundDeadline() (*RoundDeadline, error) {
+	return getCurrentRoundDeadline(db.ORM)
+}
+
+func getCurrentRoundDeadline(tx *gorm.DB) (*RoundDeadline, error) {
+	seasonID, err := getActiveSeasonID(tx)
+	if err != nil {
+		return nil, err
+	}
+	round, err := getLastSnapshotRound(tx, seasonID)
+	if err != nil {
+		return nil, err
+	}
+	deadline := &RoundDeadline{Round: round + 1}
+
+	var first Match
+	result := tx.Where("season_id = ? AND round = ?", seasonID, deadline.Round).Order("start_time asc").Limit(1).Find(&first)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected > 0 {
+		deadline.Deadline = &first.StartTime
+		deadline.Locked = !time.Now().Before(first.StartTime)
+	}
+	return deadline, nil
+}
+
+// checkTeamLock returns a TeamLockedError when the current round's deadline has passed
+func checkTeamLock(tx *gorm.DB) error {
+	deadline, err := getCurrentRoundDeadline(tx)
+	if err != nil {
+		return err
+	}
+	if deadline.Locked {
+		return &TeamLockedError{Round: deadline.Round, Deadline: *deadline.Deadline}
+	}
+	return nil
+}
@@ -0,0 +1,392 @@
+// models/headtohead.model.go
+package models
+
+import (
+	"fmt"
+	"go-orm-template/db"
+	"sort"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	LeagueModeClassic    = "classic"
+	LeagueModeHeadToHead = "h2h"
+
+	FixtureResultHome = "home"
+	FixtureResultAway = "away"
+	FixtureResultDraw = "draw"
+	FixtureResultBye  = "bye"
+
+	WinTablePoints  = 3
+	DrawTablePoints = 1
+)
+
+// LeagueFixture pairs two members of a head-to-head league for a round. AwayUserID is nil when the
+// home member has a bye. Fixtures are stored with their result once the round is snapshotted.
+type LeagueFixture struct {
+	GormModel
+	LeagueID   uint   `json:"league_id" gorm:"not null;index:idx_league_fixture_round"`
+	Round      int    `json:"round" gorm:"not null;index:idx_league_fixture_round"`
+	HomeUserID uint   `json:"home_user_id" gorm:"not null"`
+	AwayUserID *uint  `json:"away_user_id"`
+	HomePoints int    `json:"home_points"`
+	AwayPoints int    `json:"away_points"`
+	Result     string `json:"result"` // Empty until the round is settled
+}
+
+type LeagueStanding struct {
+	UserID        uint   `json:"user_id"`
+	Name          string `json:"name"`
+	TeamName      string `json:"team_name"`
+	Played        int    `json:"played"`
+	Won           int    `json:"won"`
+	Drawn         int    `json:"drawn"`
+	Lost          int    `json:"lost"`
+	PointsFor     int    `json:"points_for"`
+	PointsAgainst int    `json:"points_against"`
+	TablePoints   int    `json:"table_points"`
+	Position      int    `json:"position"`
+}
+
+// getLeagueMemberIDs returns the user IDs of the league's members in the order they joined
+func getLeagueMemberIDs(tx *gorm.DB, leagueID uint) ([]uint, error) {
+	var userIDs []uint
+	result := tx.Model(&LeagueMember{}).Where("league_id = ?", leagueID).Order("id asc").Pluck("user_id", &userIDs)
+	return userIDs, result.Error
+}
+
+// memberSlots places the members in the schedule in the order given
+func memberSlots(userIDs []uint) []*uint {
+	slots := make([]*uint, 0, len(userIDs))
+	for i := range userIDs {
+		slots = append(slots, &userIDs[i])
+	}
+	return slots
+}
+
+// getLeagueSchedule returns the schedule slots of the head-to-head league. Until the schedule is fixed
+// they are the current members in the order they joined. Afterwards the slots of removed members stay
+// empty, so the pairings never change.
+func getLeagueSchedule(tx *gorm.DB, league *League) ([]*uint, error) {
+	if !league.ScheduleFixed {
+		userIDs, err := getLeagueMemberIDs(tx, league.ID)
+		return memberSlots(userIDs), err
+	}
+
+	var members []*LeagueMember
+	result := tx.Unscoped().Where("league_id = ? AND schedule_slot IS NOT NULL", league.ID).Order("schedule_slot asc").Find(&members)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	slots := make([]*uint, len(members))
+	for i, member := range members {
+		if !member.DeletedAt.Valid {
+			slots[i] = &member.UserID
+		}
+	}
+	return slots, nil
+}
+
+// fixLeagueSchedule gives the league's members their schedule slots in the order they joined, when its
+// first round is settled. The league must be locked, as it takes no new members from then on.
+func fixLeagueSchedule(tx *gorm.DB, league *League) error {
+	if league.ScheduleFixed {
+		return nil
+	}
+	userIDs, err := getLeagueMemberIDs(tx, league.ID)
+	if err != nil {
+		return err
+	}
+	for slot, userID := range userIDs {
+		result := tx.Model(&LeagueMember{}).Where("league_id = ? AND user_id = ?", league.ID, userID).Update("schedule_slot", slot)
+		if result.Error != nil {
+			return result.Error
+		}
+	}
+	league.ScheduleFixed = true
+	return tx.Model(league).Update("schedule_fixed", true).Error
+}
+
+// buildRoundFixtures pairs the schedule slots for the round using the circle method, so every member
+// meets every other member once per cycle of len(slots)-1 rounds (len(slots) when odd). The opponent
+// of an empty slot has a bye.
+func buildRoundFixtures(league *League, slots []*uint, round int) []*LeagueFixture {
+	slots = append([]*uint{}, slots...)
+	if len(slots)%2 == 1 {
+		slots = append(slots, nil)
+	}
+	if len(slots) < 2 || round < league.StartRound {
+		return nil
+	}
+
+	// Keep the first slot fixed and rotate the others once per round
+	rotation := (round - league.StartRound) % (len(slots) - 1)
+	rotated := append([]*uint{slots[0]}, slots[1:]...)
+	for i := 0; i < rotation; i++ {
+		last := rotated[len(rotated)-1]
+		copy(rotated[2:], rotated[1:len(rotated)-1])
+		rotated[1] = last
+	}
+
+	var fixtures []*LeagueFixture
+	for i := 0; i < len(rotated)/2; i++ {
+		home, away := rotated[i], rotated[len(rotated)-1-i]
+		if (rotation+i)%2 == 1 {
+			home, away = away, home
+		}
+		if home == nil {
+			home, away = away, nil
+		}
+		if home == nil {
+			continue
+		}
+		fixture := &LeagueFixture{LeagueID: league.ID, Round: round, HomeUserID: *home, AwayUserID: away}
+		if away == nil {
+			fixture.Result = FixtureResultBye
+		}
+		fixtures = append(fixtures, fixture)
+	}
+	return fixtures
+}
+
+// scoreFixtures fills in the points of each side from the teams' round scores and decides the result
+func scoreFixtures(fixtures []*LeagueFixture, teamsByUser map[uint]*Team, scores map[uint]*teamRoundScore) {
+	pointsOf := func(userID uint) int {
+		team, ok := teamsByUser[userID]
+		if !ok {
+			return 0
+		}
+		if score, ok := scores[team.ID]; ok {
+			return score.Points
+		}
+		return 0
+	}
+
+	for _, fixture := range fixtures {
+		fixture.HomePoints = pointsOf(fixture.HomeUserID)
+		if fixture.AwayUserID == nil {
+			continue
+		}
+		fixture.AwayPoints = pointsOf(*fixture.AwayUserID)
+		switch {
+		case fixture.HomePoints > fixture.AwayPoints:
+			fixture.Result = FixtureResultHome
+		case fixture.HomePoints < fixture.AwayPoints:
+			fixture.Result = FixtureResultAway
+		default:
+			fixture.Result = FixtureResultDraw
+		}
+	}
+}
+
+func getTeamsByUserIDs(tx *gorm.DB, seasonID uint, userIDs []uint) (map[uint]*Team, error) {
+	teamsByUser := make(map[uint]*Team)
+	if len(userIDs) == 0 {
+		return teamsByUser, nil
+	}
+
+	var teams []*Team
+	result := tx.Where("season_id = ? AND user_id IN ?", seasonID, userIDs).Find(&teams)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	for _, team := range teams {
+		teamsByUser[team.UserID] = team
+	}
+	return teamsByUser, nil
+}
+
+// settleHeadToHeadRound stores the fixtures of every head-to-head league of the season for the round
+// with their results, fixing the schedule of leagues settling their first round. Settling a round again
+// replaces its fixtures.
+func settleHeadToHeadRound(tx *gorm.DB, seasonID uint, round int, scores map[uint]*teamRoundScore) error {
+	var leagues []*League
+	result := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("season_id = ? AND mode = ? AND start_round <= ?", seasonID, LeagueModeHeadToHead, round).Find(&leagues)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	for _, league := range leagues {
+		if err := tx.Unscoped().Where("league_id = ? AND round = ?", league.ID, round).Delete(&LeagueFixture{}).Error; err != nil {
+			return err
+		}
+
+		if err := fixLeagueSchedule(tx, league); err != nil {
+			return err
+		}
+		slots, err := getLeagueSchedule(tx, league)
+		if err != nil {
+			return err
+		}
+		teamsByUser, err := getTeamsByUserIDs(tx, seasonID, scheduledUserIDs(slots))
+		if err != nil {
+			return err
+		}
+
+		fixtures := buildRoundFixtures(league, slots, round)
+		if len(fixtures) == 0 {
+			continue
+		}
+		scoreFixtures(fixtures, teamsByUser, scores)
+		if err := tx.Create(&fixtures).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// scheduledUserIDs returns the members who hold a schedule slot
+func scheduledUserIDs(slots []*uint) []uint {
+	var userIDs []uint
+	for _, slot := range slots {
+		if slot != nil {
+			userIDs = append(userIDs, *slot)
+		}
+	}
+	return userIDs
+}
+
+// GetLeagueFixtures returns the head-to-head fixtures of a round. Fixtures of closed rounds come with
+// their results, those of the round in progress come with live points. Fixtures are paired from the
+// schedule fixed when the league's first round was settled, or from the current members before that.
+func GetLeagueFixtures(league *League, round int) ([]*LeagueFixture, error) {
+	if league.Mode != LeagueModeHeadToHead {
+		return nil, fmt.Errorf("%s is not a head-to-head league", league.Name)
+	}
+
+	current, err := GetCurrentRound(league.SeasonID)
+	if err != nil {
+		return nil, err
+	}
+	if round == 0 {
+		round = current
+	}
+
+	fixtures := []*LeagueFixture{}
+	if round < current {
+		result := db.ORM.Where("league_id = ? AND round = ?", league.ID, round).Order("id asc").Find(&fixtures)
+		if result.Error != nil {
+			return nil, result.Error
+		}
+		return fixtures, nil
+	}
+
+	slots, err := getLeagueSchedule(db.ORM, league)
+	if err != nil {
+		return nil, err
+	}
+	fixtures = append(fixtures, buildRoundFixtures(league, slots, round)...)
+	if round > current {
+		return fixtures, nil
+	}
+
+	teamsByUser, err := getTeamsByUserIDs(db.ORM, league.SeasonID, scheduledUserIDs(slots))
+	if err != nil {
+		return nil, err
+	}
+	var teamIDs []uint
+	for _, team := range teamsByUser {
+		teamIDs = append(teamIDs, team.ID)
+	}
+	scores, err := computeRoundScores(db.ORM, league.SeasonID, round, teamIDs)
+	if err != nil {
+		return nil, err
+	}
+	scoreFixtures(fixtures, teamsByUser, scores)
+	for _, fixture := range fixtures {
+		// Live results are provisional until the round is snapshotted
+		if fixture.Result != FixtureResultBye {
+			fixture.Result = ""
+		}
+	}
+	return fixtures, nil
+}
+
+// GetLeagueStandings returns the head-to-head table built from the settled fixtures of the league
+func GetLeagueStandings(league *League) ([]*LeagueStanding, error) {
+	if league.Mode != LeagueModeHeadToHead {
+		return nil, fmt.Errorf("%s is not a head-to-head league", league.Name)
+	}
+
+	userIDs, err := getLeagueMemberIDs(db.ORM, league.ID)
+	if err != nil {
+		return nil, err
+	}
+	teamsByUser, err := getTeamsByUserIDs(db.ORM, league.SeasonID, userIDs)
+	if err != nil {
+		return nil, err
+	}
+	var users []*User
+	if len(userIDs) > 0 {
+		if err := db.ORM.Find(&users, userIDs).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	standings := make(map[uint]*LeagueStanding)
+	for _, user := range users {
+		standing := &LeagueStanding{UserID: user.ID, Name: user.Name}
+		if team, ok := teamsByUser[user.ID]; ok {
+			standing.TeamName = team.Name
+		}
+		standings[user.ID] = standing
+	}
+
+	var fixtures []*LeagueFixture
+	result := db.ORM.Where("league_id = ? AND result <> ?", league.ID, FixtureResultBye).Find(&fixtures)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	record := func(userID uint, pointsFor int, pointsAgainst int, outcome int) {
+		standing, ok := standings[userID]
+		if !ok {
+			// Removed members keep no place in the table
+			return
+		}
+		standing.Played++
+		standing.PointsFor += pointsFor
+		standing.PointsAgainst += pointsAgainst
+		switch {
+		case outcome > 0:
+			standing.Won++
+			standing.TablePoints += WinTablePoints
+		case outcome < 0:
+			standing.Lost++
+		default:
+			standing.Drawn++
+			standing.TablePoints += DrawTablePoints
+		}
+	}
+	for _, fixture := range fixtures {
+		if fixture.AwayUserID == nil {
+			continue
+		}
+		outcome := fixture.HomePoints - fixture.AwayPoints
+		record(fixture.HomeUserID, fixture.HomePoints, fixture.AwayPoints, outcome)
+		record(*fixture.AwayUserID, fixture.AwayPoints, fixture.HomePoints, -outcome)
+	}
+
+	table := []*LeagueStanding{}
+	for _, standing := range standings {
+		table = append(table, standing)
+	}
+	sort.SliceStable(table, func(i, j int) bool {
+		if table[i].TablePoints != table[j].TablePoints {
+			return table[i].TablePoints > table[j].TablePoints
+		}
+		if table[i].PointsFor != table[j].PointsFor {
+			return table[i].PointsFor > table[j].PointsFor
+		}
+		return table[i].UserID < table[j].UserID
+	})
+	for i, standing := range table {
+		if i > 0 && standing.TablePoints == table[i-1].TablePoints && standing.PointsFor == table[i-1].PointsFor {
+			standing.Position = table[i-1].Position
+		} else {
+			standing.Position = i + 1
+		}
+	}
+	return table, nil
+}
@@ -0,0 +1,208 @@
+// models/integrity.model.go
+package models
+
+import (
+	"errors"
+	"fmt"
+	"go-orm-template/db"
+
+	"gorm.io/gorm"
+)
+
+// IntegrityIssue is a row that references a user, team, player or season that does not exist
+type IntegrityIssue struct {
+	Check  string `json:"check"`
+	Detail string `json:"detail"`
+	Fixed  bool   `json:"fixed"`
+}
+
+// IntegrityReport lists the issues found by CheckIntegrity, Fix is set when they were repaired
+type IntegrityReport struct {
+	Fix    bool              `json:"fix"`
+	Issues []*IntegrityIssue `json:"issues"`
+}
+
+func (r *IntegrityReport) add(check string, fixed bool, format string, args ...interface{}) {
+	r.Issues = append(r.Issues, &IntegrityIssue{Check: check, Detail: fmt.Sprintf(format, args...), Fixed: fixed})
+}
+
+// integrityChecker runs the checks in one transaction and remembers the teams whose squad it changed
+type integrityChecker struct {
+	tx      *gorm.DB
+	report  *IntegrityReport
+	touched map[uint]bool
+}
+
+// CheckIntegrity checks the references between users, teams and players. With fix, squad rows of
+// deleted teams and players are removed, teams of deleted users are deleted, captains outside the
+// squad are cleared and the changed teams of seasons that have not ended are recalculated. Rows without
+// a season are only reported.
+func CheckIntegrity(fix bool) (*IntegrityReport, error) {
+	report := &IntegrityReport{Fix: fix, Issues: []*IntegrityIssue{}}
+	checker := &integrityChecker{report: report, touched: make(map[uint]bool)}
+
+	err := db.ORM.Transaction(func(tx *gorm.DB) error {
+		checker.tx = tx
+		checks := []func() error{
+			checker.checkLegacyJoinTable,
+			checker.checkTeamsWithoutUser,
+			checker.checkTeamsWithoutSeason,
+			checker.checkPlayersWithoutSeason,
+			checker.checkSquadRows,
+			checker.checkCaptains,
+		}
+		for _, check := range checks {
+			if err := check(); err != nil {
+				return err
+			}
+		}
+		if !fix {
+			// Nothing is written without fix, the rollback only ends the transaction
+			return errCheckOnly
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errCheckOnly) {
+		return nil, err
+	}
+
+	var teamIDs []uint
+	for teamID := range checker.touched {
+		teamIDs = append(teamIDs, teamID)
+	}
+	if len(teamIDs) == 0 {
+		return report, nil
+	}
+
+	// Teams of ended seasons are read-only and keep their final totals
+	var writable []uint
+	result := db.ORM.Model(&Team{}).
+		Joins("JOIN seasons ON seasons.id = teams.season_id AND seasons.ended_at IS NULL").
+		Where("teams.id IN ?", teamIDs).
+		Pluck("teams.id", &writable)
+	if result.Error != nil {
+		return report, result.Error
+	}
+	if err := recomputeTeams(writable, nil); err != nil {
+		return report, err
+	}
+	return report, nil
+}
+
+var errCheckOnly = errors.New("integrity check without fix")
+
+func (c *integrityChecker) checkLegacyJoinTable() error {
+	if c.tx.Migrator().HasTable("player_teams") {
+		c.report.add("legacy_join_table", false, "player_teams still exists, run migrate to merge it into team_players")
+	}
+	return nil
+}
+
+func (c *integrityChecker) checkTeamsWithoutUser() error {
+	var teams []*Team
+	result := c.tx.Joins("LEFT JOIN users ON users.id = teams.user_id AND users.deleted_at IS NULL").
+		Where("users.id IS NULL").
+		Find(&teams)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	for _, team := range teams {
+		if c.report.Fix {
+			if err := c.tx.Where("team_id = ?", team.ID).Delete(&SquadPlayer{}).Error; err != nil {
+				return err
+			}
+			if err := c.tx.Delete(team).Error; err != nil {
+				return err
+			}
+		}
+		c.report.add("team_without_user", c.report.Fix, "team %d (%s) belongs to missing user %d", team.ID, team.Name, team.UserID)
+	}
+	return nil
+}
+
+func (c *integrityChecker) checkTeamsWithoutSeason() error {
+	var teams []*Team
+	result := c.tx.Joins("LEFT JOIN seasons ON seasons.id = teams.season_id").Where("seasons.id IS NULL").Find(&teams)
+	if result.Error != nil {
+		return result.Error
+	}
+	for _, team := range teams {
+		c.report.add("team_without_season", false, "team %d (%s) belongs to missing season %d", team.ID, team.Name, team.SeasonID)
+	}
+	return nil
+}
+
+func (c *integrityChecker) checkPlayersWithoutSeason() error {
+	var players []*Player
+	result := c.tx.Joins("LEFT JOIN seasons ON seasons.id = players.season_id").Where("seasons.id IS NULL").Find(&players)
+	if result.Error != nil {
+		return result.Error
+	}
+	for _, player := range players {
+		c.report.add("player_without_season", false, "player %d (%s) belongs to missing season %d", player.ID, player.Name, player.SeasonID)
+	}
+	return nil
+}
+
+// checkSquadRows finds team_players rows of deleted teams or players, and rows that put a player in
+// a team of another season
+func (c *integrityChecker) checkSquadRows() error {
+	checks := []struct {
+		name      string
+		condition string
+		detail    string
+	}{
+		{"squad_without_team", "teams.id IS NULL", "squad row of missing team %d with player %d"},
+		{"squad_without_player", "teams.id IS NOT NULL AND players.id IS NULL", "team %d has missing player %d"},
+		{"squad_season_mismatch", "players.season_id <> teams.season_id", "team %d has player %d of another season"},
+	}
+
+	for _, check := range checks {
+		var rows []*SquadPlayer
+		result := c.tx.Model(&SquadPlayer{}).
+			Joins("LEFT JOIN teams ON teams.id = team_players.team_id AND teams.deleted_at IS NULL").
+			Joins("LEFT JOIN players ON players.id = team_players.player_id AND players.deleted_at IS NULL").
+			Where(check.condition).
+			Find(&rows)
+		if result.Error != nil {
+			return result.Error
+		}
+
+		for _, row := range rows {
+			if c.report.Fix {
+				if err := c.tx.Where("team_id = ? AND player_id = ?", row.TeamID, row.PlayerID).Delete(&SquadPlayer{}).Error; err != nil {
+					return err
+				}
+				if check.name != "squad_without_team" {
+					c.touched[row.TeamID] = true
+				}
+			}
+			c.report.add(check.name, c.report.Fix, check.detail, row.TeamID, row.PlayerID)
+		}
+	}
+	return nil
+}
+
+// checkCaptains finds captains and vice-captains that are not in their team's squad
+func (c *integrityChecker) checkCaptains() error {
+	for _, column := range []string{"captain_id", "vice_captain_id"} {
+		var teams []*Team
+		result := c.tx.Where(column + " IS NOT NULL AND NOT EXISTS (SELECT 1 FROM team_players WHERE team_players.team_id = teams.id AND team_players.player_id = teams." + column + ")").
+			Find(&teams)
+		if result.Error != nil {
+			return result.Error
+		}
+
+		for _, team := range teams {
+			if c.report.Fix {
+				if err := c.tx.Model(team).Update(column, nil).Error; err != nil {
+					return err
+				}
+				c.touched[team.ID] = true
+			}
+			c.report.add("captain_not_in_squad", c.report.Fix, "%s of team %d (%s) is not in the squad", column, team.ID, team.Name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,318 @@
+// models/leaderboard.model.go
+package models
+
+import (
+	"fmt"
+	"go-orm-template/db"
+	"sort"
+
+	"gorm.io/gorm"
+)
+
+// TeamRoundSnapshot is a team's standing at the end of a round
+type TeamRoundSnapshot struct {
+	GormModel
+	SeasonID    uint   `json:"season_id" gorm:"uniqueIndex:idx_team_round_snapshot"`
+	Round       int    `json:"round" gorm:"not null;uniqueIndex:idx_team_round_snapshot"`
+	TeamID      uint   `json:"team_id" gorm:"not null;uniqueIndex:idx_team_round_snapshot"`
+	TeamName    string `json:"team_name"`
+	UserID      uint   `json:"user_id"`
+	Points      int    `json:"points"`
+	RoundPoints int    `json:"round_points"`
+	Value       int    `json:"value"`
+	Full        bool   `json:"full"`
+	Rank        int    `json:"rank"`        // 0 when the team was not full and therefore not ranked
+	RankChange  *int   `json:"rank_change"` // Places climbed since the previous round, nil when not ranked in both
+
+	Substitutions []*TeamRoundSubstitution `json:"substitutions,omitempty" gorm:"-"`
+}
+
+// LeaderboardEntry is a team with its position on the leaderboard
+type LeaderboardEntry struct {
+	*Team
+	Round       int  `json:"round"`
+	Rank        int  `json:"rank"`
+	RoundPoints int  `json:"round_points"`
+	RankChange  *int `json:"rank_change"`
+}
+
+// GetCurrentRound returns the season's round in progress, which is the one after the last snapshotted round
+func GetCurrentRound(seasonID uint) (int, error) {
+	round, err := getLastSnapshotRound(db.ORM, seasonID)
+	return round + 1, err
+}
+
+func getLastSnapshotRound(tx *gorm.DB, seasonID uint) (int, error) {
+	var round int
+	result := tx.Model(&TeamRoundSnapshot{}).Where("season_id = ?", seasonID).Select("COALESCE(MAX(round), 0)").Scan(&round)
+	return round, result.Error
+}
+
+// rankTeams assigns competition ranks ("1224") to the full teams ordered by points
+func rankTeams(teams []*Team) map[uint]int {
+	var ranked []*Team
+	for _, team := range teams {
+		if team.Full {
+			ranked = append(ranked, team)
+		}
+	}
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return ranked[i].Points > ranked[j].Points
+	})
+
+	ranks := make(map[uint]int)
+	for i, team := range ranked {
+		if i > 0 && team.Points == ranked[i-1].Points {
+			ranks[team.ID] = ranks[ranked[i-1].ID]
+		} else {
+			ranks[team.ID] = i + 1
+		}
+	}
+	return ranks
+}
+
+func rankChange(previous map[uint]*TeamRoundSnapshot, teamID uint, rank int) *int {
+	before, ok := previous[teamID]
+	if !ok || before.Rank == 0 || rank == 0 {
+		return nil
+	}
+	change := before.Rank - rank
+	return &change
+}
+
+func getRoundSnapshots(tx *gorm.DB, seasonID uint, round int) (map[uint]*TeamRoundSnapshot, error) {
+	var snapshots []*TeamRoundSnapshot
+	result := tx.Where("season_id = ? AND round = ?", seasonID, round).Find(&snapshots)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	byTeam := make(map[uint]*TeamRoundSnapshot)
+	for _, snapshot := range snapshots {
+		byTeam[snapshot.TeamID] = snapshot
+	}
+	return byTeam, nil
+}
+
+// getClosedRoundsPoints returns the points the team scored in the rounds up to the last closed one
+func getClosedRoundsPoints(tx *gorm.DB, teamID uint, last int) (int, error) {
+	var points int
+	result := tx.Model(&TeamRoundSnapshot{}).
+		Where("team_id = ? AND round <= ?", teamID, last).
+		Select("COALESCE(SUM(round_points), 0)").
+		Scan(&points)
+	return points, result.Error
+}
+
+// saveRoundTotals sets each team's points to its closed rounds plus the round's score, saved in case
+// its recalculation is still queued
+func saveRoundTotals(tx *gorm.DB, teams []*Team, round int, scores map[uint]*teamRoundScore) error {
+	for _, team := range teams {
+		closedPoints, err := getClosedRoundsPoints(tx, team.ID, round-1)
+		if err != nil {
+			return err
+		}
+		team.Points = closedPoints
+		if score, ok := scores[team.ID]; ok {
+			team.Points += score.Points
+		}
+		if err := tx.Model(team).Update("points", team.Points).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SnapshotRound stores every team's points, value and rank as the result of the active season's round,
+// settles the round's head-to-head fixtures and closes it. Passing 0 snapshots the current round; closed
+// rounds are never snapshotted again, as their results were built from the squads of that time.
+func SnapshotRound(round int) (int, error) {
+	err := db.ORM.Transaction(func(tx *gorm.DB) error {
+		seasonID, err := getActiveSeasonID(tx)
+		if err != nil {
+			return err
+		}
+		last, err := getLastSnapshotRound(tx, seasonID)
+		if err != nil {
+			return err
+		}
+		if round == 0 {
+			round = last + 1
+		}
+		if round <= last {
+			return fmt.Errorf("round %d is already closed, the current round is %d", round, last+1)
+		}
+		if round != last+1 {
+			return fmt.Errorf("round %d cannot be snapshotted, the current round is %d", round, last+1)
+		}
+
+		var teams []*Team
+		if err := tx.Where("season_id = ?", seasonID).Find(&teams).Error; err != nil {
+			return err
+		}
+		var teamIDs []uint
+		for _, team := range teams {
+			teamIDs = append(teamIDs, team.ID)
+		}
+
+		previous, err := getRoundSnapshots(tx, seasonID, round-1)
+		if err != nil {
+			return err
+		}
+		scores, err := computeRoundScores(tx, seasonID, round, teamIDs)
+		if err != nil {
+			return err
+		}
+
+		// Seasons scored from player totals keep the saved points
+		hasMatchData, err := seasonHasMatchData(tx, seasonID)
+		if err != nil {
+			return err
+		}
+		if hasMatchData {
+			if err := saveRoundTotals(tx, teams, round, scores); err != nil {
+				return err
+			}
+		}
+
+		ranks := rankTeams(teams)
+		for _, team := range teams {
+			snapshot := &TeamRoundSnapshot{
+				SeasonID: seasonID,
+				Round:    round,
+				TeamID:   team.ID,
+				TeamName: team.Name,
+				UserID:   team.UserID,
+				Points:   team.Points,
+				Value:    team.Value,
+				Full:     team.Full,
+				Rank:     ranks[team.ID],
+			}
+			snapshot.RankChange = rankChange(previous, team.ID, snapshot.Rank)
+
+			if score, ok := scores[team.ID]; ok {
+				snapshot.RoundPoints = score.Points
+				if len(score.Substitutions) > 0 {
+					if err := tx.Create(&score.Substitutions).Error; err != nil {
+						return err
+					}
+				}
+			}
+
+			if err := tx.Create(snapshot).Error; err != nil {
+				return err
+			}
+		}
+		return settleHeadToHeadRound(tx, seasonID, round, scores)
+	})
+	return round, err
+}
+
+// GetRoundLeaderBoard returns the ranked teams as they stood at the end of the given round of the season
+func GetRoundLeaderBoard(seasonID uint, round int) ([]*LeaderboardEntry, error) {
+	var snapshots []*TeamRoundSnapshot
+	result := db.ORM.Where("season_id = ? AND round = ? AND rank > 0", seasonID, round).Order("rank asc").Find(&snapshots)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	var teamIDs []uint
+	for _, snapshot := range snapshots {
+		teamIDs = append(teamIDs, snapshot.TeamID)
+	}
+	var teams []*Team
+	if len(teamIDs) > 0 {
+		result = db.ORM.Unscoped().Preload("User").Find(&teams, teamIDs)
+		if result.Error != nil {
+			return nil, result.Error
+		}
+	}
+	teamsByID := make(map[uint]*Team)
+	for _, team := range teams {
+		teamsByID[team.ID] = team
+	}
+
+	entries := []*LeaderboardEntry{}
+	for _, snapshot := range snapshots {
+		team, ok := teamsByID[snapshot.TeamID]
+		if !ok {
+			team = &Team{UserID: snapshot.UserID}
+			team.ID = snapshot.TeamID
+		}
+		team.Name = snapshot.TeamName
+		team.Points = snapshot.Points
+		team.Value = snapshot.Value
+		team.Full = snapshot.Full
+
+		entries = append(entries, &LeaderboardEntry{
+			Team:        team,
+			Round:       snapshot.Round,
+			Rank:        snapshot.Rank,
+			RoundPoints: snapshot.RoundPoints,
+			RankChange:  snapshot.RankChange,
+		})
+	}
+	return entries, nil
+}
+
+// GetLiveLeaderBoard returns the season's current leaderboard with the movement since the last closed round.
+// The leaderboard of an ended season is its final standings.
+func GetLiveLeaderBoard(seasonID uint) ([]*LeaderboardEntry, error) {
+	teams, err := GetTeamLeaderBoard(seasonID)
+	if err != nil {
+		return nil, err
+	}
+
+	last, err := getLastSnapshotRound(db.ORM, seasonID)
+	if err != nil {
+		return nil, err
+	}
+	previous, err := getRoundSnapshots(db.ORM, seasonID, last)
+	if err != nil {
+		return nil, err
+	}
+
+	var teamIDs []uint
+	for _, team := range teams {
+		teamIDs = append(teamIDs, team.ID)
+	}
+	scores, err := computeRoundScores(db.ORM, seasonID, last+1, teamIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	ranks := rankTeams(teams)
+	entries := []*LeaderboardEntry{}
+	for _, team := range teams {
+		entry := &LeaderboardEntry{
+			Team:       team,
+			Round:      last + 1,
+			Rank:       ranks[team.ID],
+			RankChange: rankChange(previous, team.ID, ranks[team.ID]),
+		}
+		if score, ok := scores[team.ID]; ok {
+			entry.RoundPoints = score.Points
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// GetTeamHistory returns the round by round snapshots of a team
+func GetTeamHistory(teamID uint) ([]*TeamRoundSnapshot, error) {
+	var snapshots []*TeamRoundSnapshot
+	result := db.ORM.Where("team_id = ?", teamID).Order("round asc").Find(&snapshots)
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	substitutions, err := getTeamSubstitutions(db.ORM, teamID)
+	if err != nil {
+		return nil, err
+	}
+	for _, snapshot := range snapshots {
+		snapshot.Substitutions = substitutions[snapshot.Round]
+	}
+	return snapshots, nil
+}
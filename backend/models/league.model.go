@@ -0,0 +1,305 @@
+// models/league.model.go
+package models
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"go-orm-template/db"
+	"math/big"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	LeagueRoleAdmin  = "admin"
+	LeagueRoleMember = "member"
+
+	inviteCodeLength   = 8
+	inviteCodeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789" // No 0/O or 1/I so codes can be read out loud
+)
+
+var (
+	ErrNotLeagueMember = errors.New("you are not a member of this league")
+	ErrNotLeagueAdmin  = errors.New("only league admins can manage members")
+	ErrLeagueStarted   = errors.New("head-to-head leagues cannot be joined after their first round")
+)
+
+// League is a private competition between the users who joined it with its invite code. Classic
+// leagues rank members by total points, head-to-head leagues pair them every round from StartRound.
+// The head-to-head schedule is fixed when the first round is settled, after which the league takes
+// no new members.
+type League struct {
+	GormModel
+	Name          string          `json:"name" gorm:"not null"`
+	InviteCode    string          `json:"invite_code" gorm:"uniqueIndex;not null"`
+	SeasonID      uint            `json:"season_id" gorm:"index"`
+	OwnerID       uint            `json:"owner_id" gorm:"not null"`
+	Mode          string          `json:"mode" gorm:"not null;default:classic"`
+	StartRound    int             `json:"start_round"`
+	ScheduleFixed bool            `json:"schedule_fixed"`
+	Members       []*LeagueMember `json:"members,omitempty" gorm:"foreignKey:LeagueID"`
+}
+
+// LeagueMember is a user's place in a league. ScheduleSlot is the member's position in the head-to-head
+// pairings, given to the members of the league when its schedule is fixed.
+type LeagueMember struct {
+	GormModel
+	LeagueID     uint   `json:"league_id" gorm:"not null;uniqueIndex:idx_league_member"`
+	UserID       uint   `json:"user_id" gorm:"not null;uniqueIndex:idx_league_member"`
+	User         *User  `json:"user,omitempty" gorm:"foreignKey:UserID"`
+	Role         string `json:"role"`
+	ScheduleSlot *int   `json:"schedule_slot"`
+}
+
+// newInviteCode returns a random code that is not used by another league
+func newInviteCode(tx *gorm.DB) (string, error) {
+	for attempt := 0; attempt < 5; attempt++ {
+		var code strings.Builder
+		for i := 0; i < inviteCodeLength; i++ {
+			n, err := rand.Int(rand.Reader, big.NewInt(int64(len(inviteCodeAlphabet))))
+			if err != nil {
+				return "", err
+			}
+			code.WriteByte(inviteCodeAlphabet[n.Int64()])
+		}
+
+		var count int64
+		if err := tx.Unscoped().Model(&League{}).Where("invite_code = ?", code.String()).Count(&count).Error; err != nil {
+			return "", err
+		}
+		if count == 0 {
+			return code.String(), nil
+		}
+	}
+	return "", fmt.Errorf("could not generate a unique invite code")
+}
+
+// AddLeague creates the league with a fresh invite code and makes its owner the first admin
+func AddLeague(league *League) error {
+	league.Name = strings.TrimSpace(league.Name)
+	if league.Name == "" {
+		return fmt.Errorf("league name is required")
+	}
+	if league.Mode == "" {
+		league.Mode = LeagueModeClassic
+	}
+	if league.Mode != LeagueModeClassic && league.Mode != LeagueModeHeadToHead {
+		return fmt.Errorf("league mode must be %s or %s", LeagueModeClassic, LeagueModeHeadToHead)
+	}
+
+	return db.ORM.Transaction(func(tx *gorm.DB) error {
+		code, err := newInviteCode(tx)
+		if err != nil {
+			return err
+		}
+		seasonID, err := getActiveSeasonID(tx)
+		if err != nil {
+			return err
+		}
+		last, err := getLastSnapshotRound(tx, seasonID)
+		if err != nil {
+			return err
+		}
+		league.SeasonID = seasonID
+		league.InviteCode = code
+		league.StartRound = last + 1
+		league.Members = nil
+
+		if err := tx.Create(league).Error; err != nil {
+			return err
+		}
+		return tx.Create(&LeagueMember{LeagueID: league.ID, UserID: league.OwnerID, Role: LeagueRoleAdmin}).Error
+	})
+}
+
+func GetAllLeagues() ([]*League, error) {
+	var leagues []*League
+	result := db.ORM.Preload("Members").Find(&leagues)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return leagues, nil
+}
+
+func GetLeagueByID(id string) (*League, error) {
+	var league *League
+	result := db.ORM.Preload("Members.User").First(&league, id)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return league, nil
+}
+
+// GetLeaguesByUserID returns the leagues of the season the user is a member of
+func GetLeaguesByUserID(userID uint, seasonID uint) ([]*League, error) {
+	var leagues []*League
+	result := db.ORM.Joins("JOIN league_members ON league_members.league_id = leagues.id AND league_members.deleted_at IS NULL").
+		Where("league_members.user_id = ? AND leagues.season_id = ?", userID, seasonID).
+		Order("leagues.name asc").
+		Find(&leagues)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return leagues, nil
+}
+
+// getLeagueMember returns the user's membership of the league or ErrNotLeagueMember
+func getLeagueMember(leagueID uint, userID uint) (*LeagueMember, error) {
+	var member LeagueMember
+	result := db.ORM.Where("league_id = ? AND user_id = ?", leagueID, userID).Limit(1).Find(&member)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrNotLeagueMember
+	}
+	return &member, nil
+}
+
+// CheckLeagueMember returns ErrNotLeagueMember when the user has not joined the league
+func CheckLeagueMember(league *League, userID uint) error {
+	_, err := getLeagueMember(league.ID, userID)
+	return err
+}
+
+// checkLeagueJoin returns ErrLeagueStarted when the user would join a head-to-head league whose schedule
+// is fixed. Removed members who held a slot may rejoin into it. previous is the user's old, soft deleted
+// membership, if any.
+func checkLeagueJoin(league *League, previous *LeagueMember) error {
+	if league.Mode != LeagueModeHeadToHead || !league.ScheduleFixed {
+		return nil
+	}
+	if previous != nil && previous.ScheduleSlot != nil {
+		return nil
+	}
+	return ErrLeagueStarted
+}
+
+// JoinLeagueByCode adds the user to the league with the given invite code
+func JoinLeagueByCode(code string, userID uint) (*League, error) {
+	var league *League
+	err := db.ORM.Transaction(func(tx *gorm.DB) error {
+		// The league stays locked so its schedule cannot be fixed while the user joins
+		result := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("invite_code = ?", strings.ToUpper(strings.TrimSpace(code))).First(&league)
+		if result.Error != nil {
+			if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+				return fmt.Errorf("no league found for invite code %s", code)
+			}
+			return result.Error
+		}
+		if err := checkSeasonWritable(tx, league.SeasonID); err != nil {
+			return err
+		}
+
+		// A member removed earlier rejoins through their old, soft deleted row
+		var member LeagueMember
+		result = tx.Unscoped().Where("league_id = ? AND user_id = ?", league.ID, userID).Limit(1).Find(&member)
+		if result.Error != nil {
+			return result.Error
+		}
+		var previous *LeagueMember
+		if result.RowsAffected > 0 {
+			if !member.DeletedAt.Valid {
+				return fmt.Errorf("you are already a member of %s", league.Name)
+			}
+			previous = &member
+		}
+		if err := checkLeagueJoin(league, previous); err != nil {
+			return err
+		}
+
+		if previous != nil {
+			return tx.Unscoped().Model(previous).Updates(map[string]interface{}{"deleted_at": nil, "role": LeagueRoleMember}).Error
+		}
+		return tx.Create(&LeagueMember{LeagueID: league.ID, UserID: userID, Role: LeagueRoleMember}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return league, nil
+}
+
+// RemoveLeagueMember removes a member from the league. Only league admins can remove members
+// and the owner cannot be removed.
+func RemoveLeagueMember(league *League, requesterID uint, userID uint) error {
+	requester, err := getLeagueMember(league.ID, requesterID)
+	if err != nil {
+		return err
+	}
+	if requester.Role != LeagueRoleAdmin {
+		return ErrNotLeagueAdmin
+	}
+	if userID == league.OwnerID {
+		return fmt.Errorf("the league owner cannot be removed")
+	}
+	if err := checkSeasonWritable(db.ORM, league.SeasonID); err != nil {
+		return err
+	}
+
+	member, err := getLeagueMember(league.ID, userID)
+	if err != nil {
+		if errors.Is(err, ErrNotLeagueMember) {
+			return fmt.Errorf("user %d is not a member of this league", userID)
+		}
+		return err
+	}
+	return db.ORM.Delete(member).Error
+}
+
+// GetLeagueLeaderBoard returns the live leaderboard of the league's members, ranked among themselves
+func GetLeagueLeaderBoard(league *League) ([]*LeaderboardEntry, error) {
+	var userIDs []uint
+	result := db.ORM.Model(&LeagueMember{}).Where("league_id = ?", league.ID).Pluck("user_id", &userIDs)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	members := make(map[uint]bool)
+	for _, userID := range userIDs {
+		members[userID] = true
+	}
+
+	global, err := GetLiveLeaderBoard(league.SeasonID)
+	if err != nil {
+		return nil, err
+	}
+
+	var teams []*Team
+	entries := []*LeaderboardEntry{}
+	for _, entry := range global {
+		if members[entry.UserID] {
+			teams = append(teams, entry.Team)
+			entries = append(entries, entry)
+		}
+	}
+	if len(entries) == 0 {
+		return entries, nil
+	}
+
+	// Rank movement is measured against the members' standings at the end of the last closed round
+	previous, err := getRoundSnapshots(db.ORM, league.SeasonID, entries[0].Round-1)
+	if err != nil {
+		return nil, err
+	}
+	var previousTeams []*Team
+	for _, snapshot := range previous {
+		if members[snapshot.UserID] {
+			team := &Team{Points: snapshot.Points, Full: snapshot.Rank > 0}
+			team.ID = snapshot.TeamID
+			previousTeams = append(previousTeams, team)
+		}
+	}
+	previousRanks := rankTeams(previousTeams)
+	for teamID, snapshot := range previous {
+		snapshot.Rank = previousRanks[teamID]
+	}
+
+	ranks := rankTeams(teams)
+	for _, entry := range entries {
+		entry.Rank = ranks[entry.ID]
+		entry.RankChange = rankChange(previous, entry.ID, entry.Rank)
+	}
+	return entries, nil
+}
@@ -0,0 +1,69 @@
+package models
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCheckLeagueJoin(t *testing.T) {
+	slot := 2
+	removedWithSlot := &LeagueMember{ScheduleSlot: &slot}
+	removedWithoutSlot := &LeagueMember{}
+
+	tests := []struct {
+		name     string
+		league   *League
+		previous *LeagueMember
+		wantErr  error
+	}{
+		{"classic league", &League{Mode: LeagueModeClassic, ScheduleFixed: true}, nil, nil},
+		{"head-to-head before the start", &League{Mode: LeagueModeHeadToHead}, nil, nil},
+		{"head-to-head rejoin before the start", &League{Mode: LeagueModeHeadToHead}, removedWithoutSlot, nil},
+		{"new member after the start", &League{Mode: LeagueModeHeadToHead, ScheduleFixed: true}, nil, ErrLeagueStarted},
+		{"member removed before the start", &League{Mode: LeagueModeHeadToHead, ScheduleFixed: true}, removedWithoutSlot, ErrLeagueStarted},
+		{"member removed after the start", &League{Mode: LeagueModeHeadToHead, ScheduleFixed: true}, removedWithSlot, nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := checkLeagueJoin(test.league, test.previous)
+			if !errors.Is(err, test.wantErr) {
+				t.Errorf("got %v, want %v", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestBuildRoundFixturesKeepsPairingsOfFixedSchedule(t *testing.T) {
+	a, b, c, d := uint(1), uint(2), uint(3), uint(4)
+	league := &League{StartRound: 1}
+	full := []*uint{&a, &b, &c, &d}
+	removed := []*uint{&a, nil, &c, &d} // b left after the schedule was fixed
+
+	for round := 1; round <= 3; round++ {
+		want := buildRoundFixtures(league, full, round)
+		got := buildRoundFixtures(league, removed, round)
+		if len(got) != len(want) {
+			t.Fatalf("round %d: got %d fixtures, want %d", round, len(got), len(want))
+		}
+		for i := range want {
+			wantAway := want[i].AwayUserID
+			if want[i].HomeUserID == b {
+				// The removed member's opponent has a bye
+				if got[i].HomeUserID != *wantAway || got[i].AwayUserID != nil || got[i].Result != FixtureResultBye {
+					t.Errorf("round %d: fixture %d should be a bye for %d", round, i, *wantAway)
+				}
+				continue
+			}
+			if wantAway != nil && *wantAway == b {
+				if got[i].HomeUserID != want[i].HomeUserID || got[i].AwayUserID != nil || got[i].Result != FixtureResultBye {
+					t.Errorf("round %d: fixture %d should be a bye for %d", round, i, want[i].HomeUserID)
+				}
+				continue
+			}
+			if got[i].HomeUserID != want[i].HomeUserID || got[i].AwayUserID == nil || *got[i].AwayUserID != *wantAway {
+				t.Errorf("round %d: fixture %d changed after a member left", round, i)
+			}
+		}
+	}
+}
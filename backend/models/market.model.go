@@ -0,0 +1,153 @@
+// models/market.model.go
+package models
+
+import (
+	"fmt"
+	"go-orm-template/config"
+	"go-orm-template/db"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// MarketRun records a run of the market job in a season. Transfers made after a season's last run
+// count towards the demand of the next one.
+type MarketRun struct {
+	GormModel
+	SeasonID       uint   `json:"season_id" gorm:"index"`
+	PlayersChanged int    `json:"players_changed"`
+	PlayerIDs      []uint `json:"player_ids,omitempty" gorm:"-"` // Players whose price changed, set by RunMarket only
+}
+
+// playerDemand is the number of times a player was bought less the number of times they were sold
+type playerDemand struct {
+	PlayerID uint
+	Net      int
+}
+
+// marketSteps converts net transfers into price steps, bounded by the maximum steps of a single run
+func marketSteps(net int) int {
+	if config.MarketTransfersPerStep <= 0 {
+		return 0
+	}
+	steps := net / config.MarketTransfersPerStep
+	return max(-config.MarketMaxStepsPerRun, min(config.MarketMaxStepsPerRun, steps))
+}
+
+// RunMarket nudges the price of every player of the active season by the net transfers since the last
+// run. Net transfers short of a whole step are carried over to the next run, so steady demand still moves
+// the price. The market adjustment is added to the value from the scoring rules and kept within the
+// configured bounds; every change is recorded in the player's history. The caller recalculates the teams
+// of the changed players.
+func RunMarket() (*MarketRun, error) {
+	if config.MarketIntervalMinutes <= 0 {
+		return nil, fmt.Errorf("market mode is disabled")
+	}
+
+	var run *MarketRun
+	var changed []uint
+	err := db.ORM.Transaction(func(tx *gorm.DB) error {
+		// Lock the season so concurrent runs cannot count the same transfers twice
+		var season Season
+		result := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("active = ?", true).First(&season)
+		if result.Error != nil {
+			return result.Error
+		}
+
+		var last MarketRun
+		result = tx.Where("season_id = ?", season.ID).Order("id desc").Limit(1).Find(&last)
+		if result.Error != nil {
+			return result.Error
+		}
+
+		query := tx.Model(&Transfer{}).
+			Select("transfers.player_id, SUM(CASE WHEN transfers.direction = ? THEN 1 ELSE -1 END) AS net", TransferIn).
+			Joins("JOIN players ON players.id = transfers.player_id AND players.season_id = ?", season.ID).
+			Group("transfers.player_id")
+		if last.ID != 0 {
+			query = query.Where("transfers.created_at > ?", last.CreatedAt)
+		}
+		var demand []*playerDemand
+		if err := query.Scan(&demand).Error; err != nil {
+			return err
+		}
+
+		for _, entry := range demand {
+			if entry.Net == 0 {
+				continue
+			}
+
+			var player Player
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&player, entry.PlayerID).Error; err != nil {
+				return err
+			}
+			net := player.MarketDemand + entry.Net
+			steps := marketSteps(net)
+			carried := net - steps*config.MarketTransfersPerStep
+
+			adjustment := player.MarketAdjustment + steps*config.MarketPriceStep
+			bounded := max(-config.MarketMaxAdjustment, min(config.MarketMaxAdjustment, adjustment))
+			if bounded != adjustment {
+				// Demand beyond the bounds is not saved up, it would hold the price at the bound long after it turns
+				carried = net % config.MarketTransfersPerStep
+			}
+
+			if bounded == player.MarketAdjustment {
+				if err := tx.Model(&player).Update("market_demand", carried).Error; err != nil {
+					return err
+				}
+				continue
+			}
+
+			player.MarketAdjustment = bounded
+			player.MarketDemand = carried
+			if err := savePlayer(tx, &player); err != nil {
+				return err
+			}
+			changed = append(changed, player.ID)
+		}
+
+		run = &MarketRun{SeasonID: season.ID, PlayersChanged: len(changed)}
+		return tx.Create(run).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	run.PlayerIDs = changed
+	return run, nil
+}
+
+// GetMarketRuns returns the market runs of the season, latest first
+func GetMarketRuns(seasonID uint) ([]*MarketRun, error) {
+	var runs []*MarketRun
+	result := db.ORM.Where("season_id = ?", seasonID).Order("id desc").Find(&runs)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return runs, nil
+}
+
+// StartMarketScheduler runs the market every configured interval in the background, calling onRun after
+// each successful run. It does nothing when market mode is disabled.
+func StartMarketScheduler(onRun func(run *MarketRun)) {
+	if config.MarketIntervalMinutes <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(time.Duration(config.MarketIntervalMinutes) * time.Minute)
+	go func() {
+		for range ticker.C {
+			run, err := RunMarket()
+			if err != nil {
+				fmt.Printf("Error running the market: %v\n", err)
+				continue
+			}
+			QueuePlayersRecompute(run.PlayerIDs...)
+			if onRun != nil {
+				onRun(run)
+			}
+		}
+	}()
+}
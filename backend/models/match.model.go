@@ -0,0 +1,397 @@
+// models/match.model.go
+package models
+
+import (
+	"fmt"
+	"go-orm-template/db"
+	"math"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type Match struct {
+	GormModel
+	SeasonID       uint       `json:"season_id" gorm:"index"`
+	Name           string     `json:"name"`
+	Round          int        `json:"round"`
+	HomeUniversity string     `json:"home_university"`
+	AwayUniversity string     `json:"away_university"`
+	Venue          string     `json:"venue"`
+	StartTime      time.Time  `json:"start_time"`
+	Status         string     `json:"status"` // scheduled, live or completed
+	Innings        []*Innings `json:"innings" gorm:"foreignKey:MatchID"`
+}
+
+type Innings struct {
+	GormModel
+	MatchID           uint        `json:"match_id" gorm:"not null;index"`
+	Number            int         `json:"number"`
+	BattingUniversity string      `json:"batting_university"`
+	BowlingUniversity string      `json:"bowling_university"`
+	Deliveries        []*Delivery `json:"deliveries" gorm:"foreignKey:InningsID"`
+}
+
+// Delivery is a single ball bowled in an innings. Player totals are kept in
+// sync with the recorded deliveries so every run and wicket can be traced back.
+// DismissedPlayerID names the batter who is out when it is not the striker, such as
+// a non-striker run out.
+type Delivery struct {
+	GormModel
+	InningsID         uint     `json:"innings_id" gorm:"not null;index;uniqueIndex:idx_delivery_innings_sequence,where:deleted_at IS NULL"`
+	Innings           *Innings `json:"innings,omitempty" gorm:"foreignKey:InningsID"`
+	Sequence          int      `json:"sequence" gorm:"uniqueIndex:idx_delivery_innings_sequence,where:deleted_at IS NULL"`
+	Over              int      `json:"over"`
+	Ball              int      `json:"ball"`
+	BatterID          uint     `json:"batter_id" gorm:"not null;index"`
+	BowlerID          uint     `json:"bowler_id" gorm:"not null;index"`
+	Runs              int      `json:"runs"`       // Runs off the bat
+	Extras            int      `json:"extras"`     // Runs from the extra, including the wide/no ball penalty
+	ExtraType         string   `json:"extra_type"` // wide, no_ball, bye or leg_bye
+	WicketType        string   `json:"wicket_type"`
+	DismissedPlayerID *uint    `json:"dismissed_player_id"`
+}
+
+const (
+	MatchStatusScheduled = "scheduled"
+	MatchStatusLive      = "live"
+	MatchStatusCompleted = "completed"
+)
+
+var validExtraTypes = map[string]bool{"": true, "wide": true, "no_ball": true, "bye": true, "leg_bye": true}
+
+// bowlerWickets maps every accepted wicket type to whether it is credited to the bowler
+var bowlerWickets = map[string]bool{
+	"":                      false,
+	"bowled":                true,
+	"caught":                true,
+	"lbw":                   true,
+	"stumped":               true,
+	"hit_wicket":            true,
+	"run_out":               false,
+	"retired":               false,
+	"obstructing_the_field": false,
+}
+
+// isLegal reports whether the delivery counts towards the over
+func (d *Delivery) isLegal() bool {
+	return d.ExtraType != "wide" && d.ExtraType != "no_ball"
+}
+
+// runsConceded returns the runs charged to the bowler for the delivery
+func (d *Delivery) runsConceded() int {
+	if d.ExtraType == "wide" || d.ExtraType == "no_ball" {
+		return d.Runs + d.Extras
+	}
+	return d.Runs
+}
+
+func (d *Delivery) validate() error {
+	if !validExtraTypes[d.ExtraType] {
+		return fmt.Errorf("invalid extra type %q", d.ExtraType)
+	}
+	if _, ok := bowlerWickets[d.WicketType]; !ok {
+		return fmt.Errorf("invalid wicket type %q", d.WicketType)
+	}
+	if d.Runs < 0 || d.Extras < 0 {
+		return fmt.Errorf("runs and extras cannot be negative")
+	}
+	if d.ExtraType == "" && d.Extras != 0 {
+		return fmt.Errorf("extras require an extra type")
+	}
+	// Only no balls can be hit, the runs of wides, byes and leg byes are all extras
+	if d.Runs != 0 && (d.ExtraType == "wide" || d.ExtraType == "bye" || d.ExtraType == "leg_bye") {
+		return fmt.Errorf("a %s cannot have runs off the bat, record them as extras", d.ExtraType)
+	}
+	if d.BatterID == 0 || d.BowlerID == 0 {
+		return fmt.Errorf("batter and bowler are required")
+	}
+	if d.BatterID == d.BowlerID {
+		return fmt.Errorf("batter and bowler must be different players")
+	}
+	if d.DismissedPlayerID != nil {
+		if d.WicketType == "" {
+			return fmt.Errorf("a dismissed player requires a wicket type")
+		}
+		if *d.DismissedPlayerID == d.BowlerID {
+			return fmt.Errorf("the bowler cannot be the dismissed player")
+		}
+	}
+	return nil
+}
+
+// AddMatch adds the match to the active season
+func AddMatch(match *Match) error {
+	seasonID, err := getActiveSeasonID(db.ORM)
+	if err != nil {
+		return err
+	}
+	match.SeasonID = seasonID
+	if match.Status == "" {
+		match.Status = MatchStatusScheduled
+	}
+	result := db.ORM.Create(&match)
+	return result.Error
+}
+
+func GetMatchByID(id string) (*Match, error) {
+	var match *Match
+	result := db.ORM.Preload("Innings", func(db *gorm.DB) *gorm.DB {
+		return db.Order("number asc")
+	}).Preload("Innings.Deliveries", func(db *gorm.DB) *gorm.DB {
+		return db.Order("sequence asc")
+	}).First(&match, id)
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return match, nil
+}
+
+func GetAllMatches(seasonID uint) ([]*Match, error) {
+	var matches []*Match
+
+	result := db.ORM.Where("season_id = ?", seasonID).Order("start_time asc").Find(&matches)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return matches, nil
+}
+
+// UpdateMatchByID updates an existing match record in the database
+func UpdateMatchByID(match *Match) error {
+	if err := checkSeasonWritable(db.ORM, match.SeasonID); err != nil {
+		return err
+	}
+	result := db.ORM.Save(&match)
+	return result.Error
+}
+
+// AddInningsToMatch starts a new innings for the given match
+func AddInningsToMatch(matchID string, innings *Innings) error {
+	match, err := GetMatchByID(matchID)
+	if err != nil {
+		return err
+	}
+	if match.Status == MatchStatusCompleted {
+		return fmt.Errorf("match is already completed")
+	}
+	if err := checkSeasonWritable(db.ORM, match.SeasonID); err != nil {
+		return err
+	}
+
+	innings.MatchID = match.ID
+	innings.Number = len(match.Innings) + 1
+	result := db.ORM.Create(&innings)
+	return result.Error
+}
+
+// RecordDelivery stores a ball for the innings and applies it to the batter's and bowler's totals
+func RecordDelivery(inningsID string, delivery *Delivery) ([]*PlayerChange, error) {
+	if err := delivery.validate(); err != nil {
+		return nil, err
+	}
+
+	var changes []*PlayerChange
+	err := db.ORM.Transaction(func(tx *gorm.DB) error {
+		// The innings stays locked until the ball is stored, so concurrent scorers get consecutive sequences
+		var innings Innings
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&innings, inningsID).Error; err != nil {
+			return err
+		}
+		var match Match
+		if err := tx.First(&match, innings.MatchID).Error; err != nil {
+			return err
+		}
+		if match.Status == MatchStatusCompleted {
+			return fmt.Errorf("match is already completed")
+		}
+		if err := checkSeasonWritable(tx, match.SeasonID); err != nil {
+			return err
+		}
+
+		var bowled, legal int64
+		if err := tx.Model(&Delivery{}).Where("innings_id = ?", innings.ID).Count(&bowled).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&Delivery{}).Where("innings_id = ? AND extra_type NOT IN ?", innings.ID, []string{"wide", "no_ball"}).Count(&legal).Error; err != nil {
+			return err
+		}
+
+		delivery.ID = 0
+		delivery.InningsID = innings.ID
+		delivery.Sequence = int(bowled) + 1
+		delivery.Over = int(legal) / 6
+		delivery.Ball = int(legal)%6 + 1
+
+		firstBall, err := isOnlyDeliveryForBatter(tx, delivery)
+		if err != nil {
+			return err
+		}
+		if err := tx.Create(&delivery).Error; err != nil {
+			return err
+		}
+
+		if match.Status == MatchStatusScheduled {
+			if err := tx.Model(&match).Update("status", MatchStatusLive).Error; err != nil {
+				return err
+			}
+		}
+
+		changes, err = applyDelivery(tx, delivery, 1, firstBall)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	QueuePlayersRecompute(delivery.playerIDs()...)
+	return changes, nil
+}
+
+// UndoLastDelivery removes the most recent ball of the innings and reverses it from the player totals
+func UndoLastDelivery(inningsID string) (*Delivery, []*PlayerChange, error) {
+	var delivery Delivery
+	var changes []*PlayerChange
+
+	err := db.ORM.Transaction(func(tx *gorm.DB) error {
+		var innings Innings
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&innings, inningsID).Error; err != nil {
+			return err
+		}
+		result := tx.Where("innings_id = ?", innings.ID).Order("sequence desc").First(&delivery)
+		if result.Error != nil {
+			return result.Error
+		}
+
+		var match Match
+		result = tx.Joins("JOIN innings ON innings.match_id = matches.id").Where("innings.id = ?", delivery.InningsID).First(&match)
+		if result.Error != nil {
+			return result.Error
+		}
+		if err := checkSeasonWritable(tx, match.SeasonID); err != nil {
+			return err
+		}
+
+		if err := tx.Delete(&delivery).Error; err != nil {
+			return err
+		}
+
+		lastBall, err := isOnlyDeliveryForBatter(tx, &delivery)
+		if err != nil {
+			return err
+		}
+		changes, err = applyDelivery(tx, &delivery, -1, lastBall)
+		return err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	QueuePlayersRecompute(delivery.playerIDs()...)
+	return &delivery, changes, nil
+}
+
+// playerIDs returns the players whose totals the delivery changes
+func (d *Delivery) playerIDs() []uint {
+	ids := []uint{d.BatterID, d.BowlerID}
+	if d.DismissedPlayerID != nil && *d.DismissedPlayerID != d.BatterID {
+		ids = append(ids, *d.DismissedPlayerID)
+	}
+	return ids
+}
+
+// GetDeliveriesByPlayerID returns every delivery a player batted, bowled or was dismissed in, which is the
+// audit trail for their totals
+func GetDeliveriesByPlayerID(playerID string) ([]*Delivery, error) {
+	var deliveries []*Delivery
+	result := db.ORM.Preload("Innings").
+		Where("batter_id = ? OR bowler_id = ? OR dismissed_player_id = ?", playerID, playerID, playerID).
+		Order("innings_id asc, sequence asc").
+		Find(&deliveries)
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return deliveries, nil
+}
+
+// isOnlyDeliveryForBatter reports whether the batter has no other delivery in the innings
+func isOnlyDeliveryForBatter(tx *gorm.DB, delivery *Delivery) (bool, error) {
+	var count int64
+	result := tx.Model(&Delivery{}).
+		Where("innings_id = ? AND batter_id = ? AND id <> ?", delivery.InningsID, delivery.BatterID, delivery.ID).
+		Count(&count)
+	return count == 0, result.Error
+}
+
+// isOnlyInningsForDismissed reports whether a dismissed non-striker has neither faced a ball nor been
+// dismissed in another delivery of the innings, so the delivery is what makes their innings count
+func isOnlyInningsForDismissed(tx *gorm.DB, delivery *Delivery) (bool, error) {
+	if delivery.DismissedPlayerID == nil || *delivery.DismissedPlayerID == delivery.BatterID {
+		return false, nil
+	}
+	var count int64
+	result := tx.Model(&Delivery{}).
+		Where("innings_id = ? AND id <> ? AND (batter_id = ? OR dismissed_player_id = ?)",
+			delivery.InningsID, delivery.ID, *delivery.DismissedPlayerID, *delivery.DismissedPlayerID).
+		Count(&count)
+	return count == 0, result.Error
+}
+
+// applyDelivery adds (sign 1) or removes (sign -1) a delivery from the batter's and bowler's totals,
+// and counts the innings of a non-striker dismissed before facing a ball
+func applyDelivery(tx *gorm.DB, delivery *Delivery, sign int, inningsChanged bool) ([]*PlayerChange, error) {
+	var batter, bowler Player
+	if err := tx.First(&batter, delivery.BatterID).Error; err != nil {
+		return nil, fmt.Errorf("batter not found: %w", err)
+	}
+	if err := tx.First(&bowler, delivery.BowlerID).Error; err != nil {
+		return nil, fmt.Errorf("bowler not found: %w", err)
+	}
+	oldBatter, oldBowler := batter, bowler
+
+	batter.TotalRuns += sign * delivery.Runs
+	if delivery.ExtraType != "wide" {
+		batter.BallsFaced += sign
+	}
+	if inningsChanged {
+		batter.InningsPlayed += sign
+	}
+
+	if delivery.isLegal() {
+		ballsBowled := int(math.Round(bowler.OversBowled*6)) + sign
+		bowler.OversBowled = float64(ballsBowled) / 6
+	}
+	bowler.RunsConceded += sign * delivery.runsConceded()
+	if bowlerWickets[delivery.WicketType] {
+		bowler.Wickets += sign
+	}
+
+	if err := savePlayer(tx, &batter); err != nil {
+		return nil, err
+	}
+	if err := savePlayer(tx, &bowler); err != nil {
+		return nil, err
+	}
+	changes := []*PlayerChange{{Old: &oldBatter, New: &batter}, {Old: &oldBowler, New: &bowler}}
+
+	dismissedInnings, err := isOnlyInningsForDismissed(tx, delivery)
+	if err != nil {
+		return nil, err
+	}
+	if dismissedInnings {
+		var dismissed Player
+		if err := tx.First(&dismissed, *delivery.DismissedPlayerID).Error; err != nil {
+			return nil, fmt.Errorf("dismissed player not found: %w", err)
+		}
+		oldDismissed := dismissed
+		dismissed.InningsPlayed += sign
+		if err := savePlayer(tx, &dismissed); err != nil {
+			return nil, err
+		}
+		changes = append(changes, &PlayerChange{Old: &oldDismissed, New: &dismissed})
+	}
+	return changes, nil
+}
@@ -4,10 +4,14 @@ package models
 import (
 	"go-orm-template/db"
 	"math"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type Player struct {
 	GormModel
+	SeasonID          uint     `json:"season_id" gorm:"index"`
 	Name              string   `json:"name"`
 	University        string   `json:"university"`
 	Category          string   `json:"category"`
@@ -17,9 +21,11 @@ type Player struct {
 	Wickets           int      `json:"wickets"`
 	OversBowled       float64  `json:"overs_bowled"`
 	RunsConceded      int      `json:"runs_conceded"`
-	Teams             []*Team  `json:"teams" gorm:"many2many:player_teams;"`
+	Teams             []*Team  `json:"teams" gorm:"many2many:team_players;"`
 	Points            *int     `json:"points"`
 	Value             *int     `json:"value"`
+	MarketAdjustment  int      `json:"market_adjustment"` // Added to the value by market mode from transfer demand
+	MarketDemand      int      `json:"market_demand"`     // Net transfers not yet turned into a price step
 	BattingStrikeRate *float64 `json:"batting_strike_rate"`
 	BattingAverage    *float64 `json:"batting_average"`
 	BowlingStrikeRate *float64 `json:"bowling_strike_rate"`
@@ -51,7 +57,34 @@ type PlayerForUser struct {
 	EconomyRate       *float64 `json:"economy_rate"`
 }
 
+// NewPlayerForUser returns the fields of the player that users may see, leaving out the points
+func NewPlayerForUser(player *Player) PlayerForUser {
+	return PlayerForUser{
+		ID:                player.ID,
+		Name:              player.Name,
+		University:        player.University,
+		Category:          player.Category,
+		TotalRuns:         player.TotalRuns,
+		BallsFaced:        player.BallsFaced,
+		InningsPlayed:     player.InningsPlayed,
+		Wickets:           player.Wickets,
+		OversBowled:       player.OversBowled,
+		RunsConceded:      player.RunsConceded,
+		Value:             player.Value,
+		BattingStrikeRate: player.BattingStrikeRate,
+		BattingAverage:    player.BattingAverage,
+		BowlingStrikeRate: player.BowlingStrikeRate,
+		EconomyRate:       player.EconomyRate,
+	}
+}
+
+// CalculatePlayerStats derives the rates, points and value of a player using the active scoring rules
 func CalculatePlayerStats(player *Player) {
+	CalculatePlayerStatsWithRules(player, GetActiveScoringRules())
+}
+
+// CalculatePlayerStatsWithRules derives the rates, points and value of a player using the given scoring rules
+func CalculatePlayerStatsWithRules(player *Player, rules *ScoringRuleSet) {
 	battingStrikeRate := safeDivide(float64(player.TotalRuns)*100, float64(player.BallsFaced))
 	battingAverage := safeDivide(float64(player.TotalRuns), float64(player.InningsPlayed))
 	bowlingStrikeRate := safeDivide(float64(player.OversBowled)*100, float64(player.Wickets))
@@ -83,24 +116,25 @@ func CalculatePlayerStats(player *Player) {
 
 	var points int
 	if player.BattingStrikeRate != nil {
-		points += int(safeDivide(float64(*player.BattingStrikeRate), 5.0))
+		points += int(safeDivide(float64(*player.BattingStrikeRate), rules.BattingStrikeRateDivisor))
 	}
 	if player.BattingAverage != nil {
-		points += int(float64(*player.BattingAverage) * 0.8)
+		points += int(float64(*player.BattingAverage) * rules.BattingAverageMultiplier)
 	}
 
 	if player.BowlingStrikeRate != nil && *player.BowlingStrikeRate > 0 {
-		points += int(safeDivide(500.0, float64(*player.BowlingStrikeRate)))
+		points += int(safeDivide(rules.BowlingStrikeRateNumerator, float64(*player.BowlingStrikeRate)))
 	}
 
 	if player.EconomyRate != nil && *player.EconomyRate > 0 {
-		points += int(safeDivide(140.0, float64(*player.EconomyRate)))
+		points += int(safeDivide(rules.EconomyRateNumerator, float64(*player.EconomyRate)))
 	}
 
 	player.Points = &points
 
-	value := int((9*float64(*player.Points) + 100) * 1000)
-	value = (value + 25000) / 50000 * 50000 // Round to the nearest multiple of 50,000
+	value := int((rules.ValueMultiplier*float64(*player.Points) + rules.ValueOffset) * rules.ValueScale)
+	value = (value + rules.ValueRounding/2) / rules.ValueRounding * rules.ValueRounding // Round to the nearest multiple of the rounding step
+	value = max(0, value+player.MarketAdjustment)
 	player.Value = &value
 
 	roundedBattingStrikeRate := math.Round(battingStrikeRate*100) / 100
@@ -129,10 +163,25 @@ func safeDivide(numerator, denominator float64) float64 {
 	return numerator / denominator
 }
 
-func AddPlayer(player *Player) error {
+// savePlayer recalculates the derived stats of a player and saves it within the given transaction,
+// recording a snapshot in the player's history when the points or value changed
+func savePlayer(tx *gorm.DB, player *Player) error {
 	CalculatePlayerStats(player)
-	result := db.ORM.Create(&player)
-	return result.Error
+	result := tx.Save(player)
+	if result.Error != nil {
+		return result.Error
+	}
+	return recordPlayerStatSnapshot(tx, player)
+}
+
+// AddPlayer adds the player to the active season
+func AddPlayer(player *Player) error {
+	seasonID, err := getActiveSeasonID(db.ORM)
+	if err != nil {
+		return err
+	}
+	player.SeasonID = seasonID
+	return savePlayer(db.ORM, player)
 }
 
 // GetPlayerByID retrieves a player record from the database by ID
@@ -146,10 +195,10 @@ func GetPlayerByID(id string) (*Player, error) {
 	return player, nil
 }
 
-func GetAllPlayers() ([]*Player, error) {
+func GetAllPlayers(seasonID uint) ([]*Player, error) {
 	var players []*Player
 
-	result := db.ORM.Find(&players)
+	result := db.ORM.Where("season_id = ?", seasonID).Find(&players)
 	if result.Error != nil {
 		return nil, result.Error
 	}
@@ -168,28 +217,90 @@ func GetPlayersByFilters(filters map[string]interface{}) ([]*Player, error) {
 	return players, nil
 }
 
-// UpdatePlayerByID updates an existing player record in the database
-func UpdatePlayerByID(player *Player) error {
-	CalculatePlayerStats(player)
-	result := db.ORM.Save(&player)
+// PlayerDetails are the fields of a player admins can edit, the totals are derived from the match data
+type PlayerDetails struct {
+	Name       string `json:"name"`
+	University string `json:"university"`
+	Category   string `json:"category"`
+}
 
-	go UpdateAllTeamsPointsAndValue()
+// UpdatePlayerByID changes the details of the stored player and returns it before and after the change.
+// Players of ended seasons are read-only and players never move between seasons.
+func UpdatePlayerByID(id string, details PlayerDetails) (*Player, *Player, error) {
+	var old, player Player
+	err := db.ORM.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&player, id).Error; err != nil {
+			return err
+		}
+		if err := checkSeasonWritable(tx, player.SeasonID); err != nil {
+			return err
+		}
 
-	return result.Error
+		old = player
+		player.Name = details.Name
+		player.University = details.University
+		player.Category = details.Category
+		return tx.Model(&player).Updates(map[string]interface{}{
+			"name":       player.Name,
+			"university": player.University,
+			"category":   player.Category,
+		}).Error
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	QueuePlayersRecompute(player.ID)
+	return &old, &player, nil
 }
 
-// DeletePlayerByID deletes a player record from the database by ID
-func DeletePlayerByID(id string) error {
-	var player *Player
-	result := db.ORM.Delete(&player, id)
+// SavePlayers recalculates and saves the given players in a single transaction. The caller recalculates
+// their teams, imports do so before they exit instead of queueing it.
+func SavePlayers(players []*Player) error {
+	err := db.ORM.Transaction(func(tx *gorm.DB) error {
+		for _, player := range players {
+			if err := savePlayer(tx, player); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return err
+}
+
+// DeletePlayersByIDs deletes the player records with the given IDs
+func DeletePlayersByIDs(ids []uint) error {
+	result := db.ORM.Delete(&Player{}, ids)
+	return result.Error
+}
 
-	go UpdateAllTeamsPointsAndValue()
+// DeletePlayersBySeasonID deletes every player of the season
+func DeletePlayersBySeasonID(seasonID uint) error {
+	result := db.ORM.Where("season_id = ?", seasonID).Delete(&Player{})
 	return result.Error
 }
 
-func GetTournamentSummary() (*TournamentSummary, error) {
+// DeletePlayerByID deletes a player record from the database by ID and returns the deleted player
+func DeletePlayerByID(id string) (*Player, error) {
+	player, err := GetPlayerByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkSeasonWritable(db.ORM, player.SeasonID); err != nil {
+		return nil, err
+	}
+	result := db.ORM.Delete(&player)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	QueuePlayersRecompute(player.ID)
+	return player, nil
+}
+
+func GetTournamentSummary(seasonID uint) (*TournamentSummary, error) {
 	var players []*Player
-	result := db.ORM.Find(&players)
+	result := db.ORM.Where("season_id = ?", seasonID).Find(&players)
 	if result.Error != nil {
 		return nil, result.Error
 	}
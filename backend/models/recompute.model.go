@@ -0,0 +1,210 @@
+// models/recompute.model.go
+package models
+
+import (
+	"fmt"
+	"go-orm-template/config"
+	"go-orm-template/db"
+	"sync"
+	"time"
+)
+
+// TeamRecomputeStatus reports the progress of the background team recalculation
+type TeamRecomputeStatus struct {
+	Running        bool       `json:"running"`
+	QueuedPlayers  int        `json:"queued_players"` // Changed players waiting for the next run
+	QueuedAll      bool       `json:"queued_all"`     // Every team of the active season is waiting for the next run
+	TeamsTotal     int        `json:"teams_total"`    // Teams of the current or last run
+	TeamsDone      int        `json:"teams_done"`
+	Runs           int        `json:"runs"`
+	LastStartedAt  *time.Time `json:"last_started_at"`
+	LastFinishedAt *time.Time `json:"last_finished_at"`
+	LastError      string     `json:"last_error"`
+}
+
+// teamRecomputer collects changed players and recalculates the teams that own them in batches. Changes
+// arriving within the debounce window, or while a run is in progress, are handled by a single run.
+type teamRecomputer struct {
+	mu        sync.Mutex
+	players   map[uint]bool
+	all       bool
+	scheduled bool
+	status    TeamRecomputeStatus
+}
+
+var recomputer = &teamRecomputer{players: make(map[uint]bool)}
+
+// QueuePlayersRecompute schedules the recalculation of the teams that contain any of the players
+func QueuePlayersRecompute(playerIDs ...uint) {
+	recomputer.mu.Lock()
+	defer recomputer.mu.Unlock()
+
+	for _, playerID := range playerIDs {
+		recomputer.players[playerID] = true
+	}
+	recomputer.schedule()
+}
+
+// QueueAllTeamsRecompute schedules the recalculation of every team of the active season
+func QueueAllTeamsRecompute() {
+	recomputer.mu.Lock()
+	defer recomputer.mu.Unlock()
+
+	recomputer.all = true
+	recomputer.schedule()
+}
+
+// GetTeamRecomputeStatus returns the progress of the background team recalculation
+func GetTeamRecomputeStatus() TeamRecomputeStatus {
+	recomputer.mu.Lock()
+	defer recomputer.mu.Unlock()
+
+	status := recomputer.status
+	status.QueuedPlayers = len(recomputer.players)
+	status.QueuedAll = recomputer.all
+	return status
+}
+
+// schedule starts a run after the debounce window, unless one is already scheduled or running.
+// The caller must hold the lock.
+func (r *teamRecomputer) schedule() {
+	if r.scheduled || r.status.Running {
+		return
+	}
+	r.scheduled = true
+	time.AfterFunc(time.Duration(config.TeamRecomputeDebounceMs)*time.Millisecond, r.run)
+}
+
+// run recalculates the queued teams, repeating while changes keep arriving during a run
+func (r *teamRecomputer) run() {
+	for {
+		r.mu.Lock()
+		r.scheduled = false
+		if len(r.players) == 0 && !r.all {
+			r.status.Running = false
+			r.mu.Unlock()
+			return
+		}
+		playerIDs := make([]uint, 0, len(r.players))
+		for playerID := range r.players {
+			playerIDs = append(playerIDs, playerID)
+		}
+		all := r.all
+		r.players = make(map[uint]bool)
+		r.all = false
+
+		now := time.Now()
+		r.status.Running = true
+		r.status.Runs++
+		r.status.TeamsTotal = 0
+		r.status.TeamsDone = 0
+		r.status.LastStartedAt = &now
+		r.status.LastError = ""
+		r.mu.Unlock()
+
+		var err error
+		progress := func(done int, total int) {
+			r.mu.Lock()
+			r.status.TeamsDone = done
+			r.status.TeamsTotal = total
+			r.mu.Unlock()
+		}
+		if all {
+			err = RecomputeAllTeams(progress)
+		} else {
+			err = RecomputeTeamsForPlayers(playerIDs, progress)
+		}
+
+		finished := time.Now()
+		r.mu.Lock()
+		r.status.LastFinishedAt = &finished
+		if err != nil {
+			r.status.LastError = err.Error()
+			fmt.Printf("Error recalculating teams: %v\n", err)
+		}
+		r.mu.Unlock()
+	}
+}
+
+// RecomputeTeamsForPlayers recalculates the active season's teams that contain any of the players,
+// found through the team_players join table, reporting progress after every team
+func RecomputeTeamsForPlayers(playerIDs []uint, progress func(done int, total int)) error {
+	if len(playerIDs) == 0 {
+		return nil
+	}
+	seasonID, err := getActiveSeasonID(db.ORM)
+	if err != nil {
+		return err
+	}
+
+	var teamIDs []uint
+	result := db.ORM.Model(&SquadPlayer{}).
+		Distinct("team_players.team_id").
+		Joins("JOIN teams ON teams.id = team_players.team_id AND teams.deleted_at IS NULL").
+		Where("team_players.player_id IN ? AND teams.season_id = ?", playerIDs, seasonID).
+		Pluck("team_players.team_id", &teamIDs)
+	if result.Error != nil {
+		return result.Error
+	}
+	return recomputeTeams(teamIDs, progress)
+}
+
+// RecomputeAllTeams recalculates every team of the active season, teams of ended seasons keep their final totals
+func RecomputeAllTeams(progress func(done int, total int)) error {
+	seasonID, err := getActiveSeasonID(db.ORM)
+	if err != nil {
+		return err
+	}
+
+	var teamIDs []uint
+	result := db.ORM.Model(&Team{}).Where("season_id = ?", seasonID).Pluck("id", &teamIDs)
+	if result.Error != nil {
+		return result.Error
+	}
+	return recomputeTeams(teamIDs, progress)
+}
+
+// recomputeTeams refreshes each team in its own transaction, so a failing team does not stop the others.
+// The round's player points are loaded once per season rather than for every team.
+func recomputeTeams(teamIDs []uint, progress func(done int, total int)) error {
+	if len(teamIDs) == 0 {
+		return nil
+	}
+	var teams []*Team
+	if err := db.ORM.Select("id", "season_id").Find(&teams, teamIDs).Error; err != nil {
+		return err
+	}
+	seasonByTeam := make(map[uint]uint)
+	for _, team := range teams {
+		seasonByTeam[team.ID] = team.SeasonID
+	}
+
+	sources := make(map[uint]*teamPointsSource)
+	var failed []uint
+	var lastErr error
+	for i, teamID := range teamIDs {
+		seasonID := seasonByTeam[teamID]
+		source, ok := sources[seasonID]
+		var err error
+		if !ok {
+			source, err = loadTeamPointsSource(db.ORM, seasonID)
+			if err == nil {
+				sources[seasonID] = source
+			}
+		}
+		if err == nil {
+			err = refreshTeamPointsAndValue(teamID, source)
+		}
+		if err != nil {
+			failed = append(failed, teamID)
+			lastErr = err
+		}
+		if progress != nil {
+			progress(i+1, len(teamIDs))
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("could not recalculate teams %v: %w", failed, lastErr)
+	}
+	return nil
+}
@@ -0,0 +1,247 @@
+// models/round.model.go
+package models
+
+import (
+	"math"
+
+	"gorm.io/gorm"
+)
+
+// TeamRoundSubstitution records a bench player who replaced a starter that did not play in the round
+type TeamRoundSubstitution struct {
+	GormModel
+	Round       int  `json:"round" gorm:"not null;index"`
+	TeamID      uint `json:"team_id" gorm:"not null;index"`
+	OutPlayerID uint `json:"out_player_id"`
+	InPlayerID  uint `json:"in_player_id"`
+}
+
+type teamRoundScore struct {
+	Points        int
+	Substitutions []*TeamRoundSubstitution
+}
+
+// getPlayerRoundPoints returns the points each player earned in the matches of the season's round.
+// Players without a scorecard line or delivery in the round did not play and are left out.
+func getPlayerRoundPoints(tx *gorm.DB, seasonID uint, round int) (map[uint]int, error) {
+	stats := make(map[uint]*Player)
+	ballsBowled := make(map[uint]int)
+	statsFor := func(playerID uint) *Player {
+		if _, ok := stats[playerID]; !ok {
+			stats[playerID] = &Player{}
+		}
+		return stats[playerID]
+	}
+
+	var lines []*PlayerMatchStat
+	result := tx.Joins("JOIN matches ON matches.id = player_match_stats.match_id AND matches.deleted_at IS NULL").
+		Where("matches.season_id = ? AND matches.round = ?", seasonID, round).
+		Find(&lines)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	for _, line := range lines {
+		player := statsFor(line.PlayerID)
+		player.TotalRuns += line.Runs
+		player.BallsFaced += line.BallsFaced
+		player.InningsPlayed += boolToInt(line.Batted)
+		player.Wickets += line.Wickets
+		player.RunsConceded += line.RunsConceded
+		ballsBowled[line.PlayerID] += line.BallsBowled
+	}
+
+	var deliveries []*Delivery
+	result = tx.Joins("JOIN innings ON innings.id = deliveries.innings_id AND innings.deleted_at IS NULL").
+		Joins("JOIN matches ON matches.id = innings.match_id AND matches.deleted_at IS NULL").
+		Where("matches.season_id = ? AND matches.round = ?", seasonID, round).
+		Find(&deliveries)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	batted := make(map[[2]uint]bool)
+	for _, delivery := range deliveries {
+		batter := statsFor(delivery.BatterID)
+		batter.TotalRuns += delivery.Runs
+		if delivery.ExtraType != "wide" {
+			batter.BallsFaced++
+		}
+		if key := [2]uint{delivery.InningsID, delivery.BatterID}; !batted[key] {
+			batted[key] = true
+			batter.InningsPlayed++
+		}
+		// A non-striker run out before facing a ball still batted in the innings
+		if dismissedID := delivery.DismissedPlayerID; dismissedID != nil {
+			if key := [2]uint{delivery.InningsID, *dismissedID}; !batted[key] {
+				batted[key] = true
+				statsFor(*dismissedID).InningsPlayed++
+			}
+		}
+
+		bowler := statsFor(delivery.BowlerID)
+		if delivery.isLegal() {
+			ballsBowled[delivery.BowlerID]++
+		}
+		bowler.RunsConceded += delivery.runsConceded()
+		if bowlerWickets[delivery.WicketType] {
+			bowler.Wickets++
+		}
+	}
+
+	rules, err := getSeasonScoringRules(tx, seasonID)
+	if err != nil {
+		return nil, err
+	}
+	points := make(map[uint]int)
+	for playerID, player := range stats {
+		player.OversBowled = float64(ballsBowled[playerID]) / 6
+		CalculatePlayerStatsWithRules(player, rules)
+		points[playerID] = intValue(player.Points)
+	}
+	return points, nil
+}
+
+// computeTeamRoundScore scores the team's starting players for the round, replacing starters who did
+// not play with the first bench player who did. When the captain did not play the vice-captain
+// takes the captain's multiplier.
+func computeTeamRoundScore(team *Team, round int, points map[uint]int, penalty int) *teamRoundScore {
+	score := &teamRoundScore{}
+	starters, bench := team.splitSquad()
+
+	played := func(player *Player) bool {
+		_, ok := points[player.ID]
+		return ok
+	}
+
+	used := make(map[uint]bool)
+	var lineup []*Player
+	for _, starter := range starters {
+		if played(starter) {
+			lineup = append(lineup, starter)
+			continue
+		}
+		for _, substitute := range bench {
+			if !used[substitute.ID] && played(substitute) {
+				used[substitute.ID] = true
+				lineup = append(lineup, substitute)
+				score.Substitutions = append(score.Substitutions, &TeamRoundSubstitution{
+					Round:       round,
+					TeamID:      team.ID,
+					OutPlayerID: starter.ID,
+					InPlayerID:  substitute.ID,
+				})
+				break
+			}
+		}
+	}
+
+	captainPlayed := containsPlayer(lineup, team.CaptainID)
+	total := 0.0
+	for _, player := range lineup {
+		multiplier := team.pointsMultiplier(player.ID)
+		if !captainPlayed && team.ViceCaptainID != nil && *team.ViceCaptainID == player.ID {
+			multiplier = CaptainMultiplier
+		}
+		total += math.Round(float64(points[player.ID]) * multiplier)
+	}
+
+	score.Points = int(total) - penalty
+	return score
+}
+
+// computeRoundScores scores every given team of the season for the round
+func computeRoundScores(tx *gorm.DB, seasonID uint, round int, teamIDs []uint) (map[uint]*teamRoundScore, error) {
+	if len(teamIDs) == 0 {
+		return make(map[uint]*teamRoundScore), nil
+	}
+
+	var teams []*Team
+	result := tx.Preload("Players").Preload("Squad").Find(&teams, teamIDs)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return scoreTeamsForRound(tx, seasonID, round, teams)
+}
+
+// seasonHasMatchData reports whether any scorecard line or delivery was recorded for the season's matches
+func seasonHasMatchData(tx *gorm.DB, seasonID uint) (bool, error) {
+	var lines int64
+	result := tx.Model(&PlayerMatchStat{}).
+		Joins("JOIN matches ON matches.id = player_match_stats.match_id AND matches.deleted_at IS NULL").
+		Where("matches.season_id = ?", seasonID).
+		Limit(1).
+		Count(&lines)
+	if result.Error != nil || lines > 0 {
+		return lines > 0, result.Error
+	}
+
+	var deliveries int64
+	result = tx.Model(&Delivery{}).
+		Joins("JOIN innings ON innings.id = deliveries.innings_id AND innings.deleted_at IS NULL").
+		Joins("JOIN matches ON matches.id = innings.match_id AND matches.deleted_at IS NULL").
+		Where("matches.season_id = ?", seasonID).
+		Limit(1).
+		Count(&deliveries)
+	return deliveries > 0, result.Error
+}
+
+// scoreTeamsForRound scores the teams, loaded with their squads, for the season's round with the
+// transfer penalties of the round
+func scoreTeamsForRound(tx *gorm.DB, seasonID uint, round int, teams []*Team) (map[uint]*teamRoundScore, error) {
+	if len(teams) == 0 {
+		return make(map[uint]*teamRoundScore), nil
+	}
+	points, err := getPlayerRoundPoints(tx, seasonID, round)
+	if err != nil {
+		return nil, err
+	}
+	return scoreTeamsWithPoints(tx, round, teams, points)
+}
+
+// scoreTeamsWithPoints scores the teams for the round from the players' points already loaded for it
+func scoreTeamsWithPoints(tx *gorm.DB, round int, teams []*Team, points map[uint]int) (map[uint]*teamRoundScore, error) {
+	scores := make(map[uint]*teamRoundScore)
+	if len(teams) == 0 {
+		return scores, nil
+	}
+	var teamIDs []uint
+	for _, team := range teams {
+		teamIDs = append(teamIDs, team.ID)
+	}
+
+	var penalties []struct {
+		TeamID  uint
+		Penalty int
+	}
+	result := tx.Model(&Transfer{}).
+		Select("team_id, SUM(penalty_points) AS penalty").
+		Where("round = ? AND team_id IN ?", round, teamIDs).
+		Group("team_id").
+		Scan(&penalties)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	penaltyByTeam := make(map[uint]int)
+	for _, penalty := range penalties {
+		penaltyByTeam[penalty.TeamID] = penalty.Penalty
+	}
+
+	for _, team := range teams {
+		scores[team.ID] = computeTeamRoundScore(team, round, points, penaltyByTeam[team.ID])
+	}
+	return scores, nil
+}
+
+// getTeamSubstitutions returns the substitutions made for a team, grouped by round
+func getTeamSubstitutions(tx *gorm.DB, teamID uint) (map[int][]*TeamRoundSubstitution, error) {
+	var substitutions []*TeamRoundSubstitution
+	result := tx.Where("team_id = ?", teamID).Order("round asc, id asc").Find(&substitutions)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	byRound := make(map[int][]*TeamRoundSubstitution)
+	for _, substitution := range substitutions {
+		byRound[substitution.Round] = append(byRound[substitution.Round], substitution)
+	}
+	return byRound, nil
+}
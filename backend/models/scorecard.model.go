@@ -0,0 +1,105 @@
+// models/scorecard.model.go
+package models
+
+import (
+	"go-orm-template/db"
+	"math"
+
+	"gorm.io/gorm"
+)
+
+// PlayerMatchStat is a player's batting and bowling line for one match, as imported from a scorecard
+type PlayerMatchStat struct {
+	GormModel
+	MatchID      uint    `json:"match_id" gorm:"not null;uniqueIndex:idx_player_match_stat"`
+	Match        *Match  `json:"match,omitempty" gorm:"foreignKey:MatchID"`
+	PlayerID     uint    `json:"player_id" gorm:"not null;uniqueIndex:idx_player_match_stat"`
+	Player       *Player `json:"player,omitempty" gorm:"foreignKey:PlayerID"`
+	Runs         int     `json:"runs"`
+	BallsFaced   int     `json:"balls_faced"`
+	Batted       bool    `json:"batted"`
+	Wickets      int     `json:"wickets"`
+	BallsBowled  int     `json:"balls_bowled"`
+	RunsConceded int     `json:"runs_conceded"`
+}
+
+// FindOrCreateMatch returns the active season's match with the same name and round, creating it when it does not exist
+func FindOrCreateMatch(match *Match) error {
+	seasonID, err := getActiveSeasonID(db.ORM)
+	if err != nil {
+		return err
+	}
+	match.SeasonID = seasonID
+	if match.Status == "" {
+		match.Status = MatchStatusCompleted
+	}
+	result := db.ORM.Where("season_id = ? AND name = ? AND round = ?", seasonID, match.Name, match.Round).FirstOrCreate(match)
+	return result.Error
+}
+
+// GetPlayerByNameAndUniversity retrieves a player of the active season by its name and university
+func GetPlayerByNameAndUniversity(name string, university string) (*Player, error) {
+	seasonID, err := getActiveSeasonID(db.ORM)
+	if err != nil {
+		return nil, err
+	}
+
+	var player *Player
+	result := db.ORM.Where("season_id = ? AND name = ? AND university = ?", seasonID, name, university).First(&player)
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return player, nil
+}
+
+// GetMatchStatsByMatchID returns every scorecard line recorded for a match
+func GetMatchStatsByMatchID(matchID string) ([]*PlayerMatchStat, error) {
+	var stats []*PlayerMatchStat
+	result := db.ORM.Preload("Player").Where("match_id = ?", matchID).Find(&stats)
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return stats, nil
+}
+
+// ApplyPlayerMatchStat stores a scorecard line and adds it to the player's totals. Importing the
+// same line again only applies the difference, so a round can be re-imported after corrections. The
+// import recalculates the teams of the changed players once all lines are applied.
+func ApplyPlayerMatchStat(stat *PlayerMatchStat) error {
+	return db.ORM.Transaction(func(tx *gorm.DB) error {
+		var player Player
+		if err := tx.First(&player, stat.PlayerID).Error; err != nil {
+			return err
+		}
+
+		var previous PlayerMatchStat
+		result := tx.Where("match_id = ? AND player_id = ?", stat.MatchID, stat.PlayerID).Limit(1).Find(&previous)
+		if result.Error != nil {
+			return result.Error
+		}
+
+		player.TotalRuns += stat.Runs - previous.Runs
+		player.BallsFaced += stat.BallsFaced - previous.BallsFaced
+		player.InningsPlayed += boolToInt(stat.Batted) - boolToInt(previous.Batted)
+		player.Wickets += stat.Wickets - previous.Wickets
+		player.RunsConceded += stat.RunsConceded - previous.RunsConceded
+		ballsBowled := int(math.Round(player.OversBowled*6)) + stat.BallsBowled - previous.BallsBowled
+		player.OversBowled = float64(ballsBowled) / 6
+
+		stat.ID = previous.ID
+		stat.CreatedAt = previous.CreatedAt
+		if err := tx.Save(stat).Error; err != nil {
+			return err
+		}
+		return savePlayer(tx, &player)
+	})
+}
+
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
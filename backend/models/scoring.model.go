@@ -0,0 +1,291 @@
+// models/scoring.model.go
+package models
+
+import (
+	"fmt"
+	"go-orm-template/db"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ScoringRuleSet holds the weights of the fantasy points and value formulas. Rule sets are
+// versioned and only one is active per season at a time; once activated a rule set can no longer be edited.
+type ScoringRuleSet struct {
+	GormModel
+	SeasonID                   uint       `json:"season_id" gorm:"uniqueIndex:idx_scoring_rule_set_season_version"`
+	Name                       string     `json:"name"`
+	Version                    int        `json:"version" gorm:"uniqueIndex:idx_scoring_rule_set_season_version"` // Numbered from 1 in every season
+	Active                     bool       `json:"active"`
+	ActivatedAt                *time.Time `json:"activated_at"`
+	BattingStrikeRateDivisor   float64    `json:"batting_strike_rate_divisor"`
+	BattingAverageMultiplier   float64    `json:"batting_average_multiplier"`
+	BowlingStrikeRateNumerator float64    `json:"bowling_strike_rate_numerator"`
+	EconomyRateNumerator       float64    `json:"economy_rate_numerator"`
+	ValueMultiplier            float64    `json:"value_multiplier"`
+	ValueOffset                float64    `json:"value_offset"`
+	ValueScale                 float64    `json:"value_scale"`
+	ValueRounding              int        `json:"value_rounding"`
+}
+
+type PlayerScoringPreview struct {
+	ID            uint   `json:"id"`
+	Name          string `json:"name"`
+	CurrentPoints int    `json:"current_points"`
+	NewPoints     int    `json:"new_points"`
+	PointsChange  int    `json:"points_change"`
+	CurrentValue  int    `json:"current_value"`
+	NewValue      int    `json:"new_value"`
+	ValueChange   int    `json:"value_change"`
+}
+
+var (
+	activeScoringRules      *ScoringRuleSet
+	activeScoringRulesMutex sync.RWMutex
+)
+
+// DefaultScoringRules returns the original scoring formula, used until a rule set is activated
+func DefaultScoringRules() *ScoringRuleSet {
+	return &ScoringRuleSet{
+		Name:                       "Default",
+		BattingStrikeRateDivisor:   5,
+		BattingAverageMultiplier:   0.8,
+		BowlingStrikeRateNumerator: 500,
+		EconomyRateNumerator:       140,
+		ValueMultiplier:            9,
+		ValueOffset:                100,
+		ValueScale:                 1000,
+		ValueRounding:              50000,
+	}
+}
+
+func (rules *ScoringRuleSet) validate() error {
+	if rules.BattingStrikeRateDivisor <= 0 {
+		return fmt.Errorf("batting strike rate divisor must be greater than 0")
+	}
+	if rules.ValueScale <= 0 {
+		return fmt.Errorf("value scale must be greater than 0")
+	}
+	if rules.ValueRounding <= 0 {
+		return fmt.Errorf("value rounding must be greater than 0")
+	}
+	return nil
+}
+
+// GetActiveScoringRules returns the active rule set of the active season, loading it from the database on first use.
+// When it cannot be loaded the default rules are used and loading is tried again on the next call.
+func GetActiveScoringRules() *ScoringRuleSet {
+	activeScoringRulesMutex.RLock()
+	rules := activeScoringRules
+	activeScoringRulesMutex.RUnlock()
+	if rules != nil {
+		return rules
+	}
+
+	seasonID, err := getActiveSeasonID(db.ORM)
+	if err != nil {
+		return DefaultScoringRules()
+	}
+	rules, err = getSeasonScoringRules(db.ORM, seasonID)
+	if err != nil {
+		return DefaultScoringRules()
+	}
+
+	setActiveScoringRules(rules)
+	return rules
+}
+
+// getSeasonScoringRules returns the rule set active in the season, or the default rules when none was activated
+func getSeasonScoringRules(tx *gorm.DB, seasonID uint) (*ScoringRuleSet, error) {
+	var active ScoringRuleSet
+	result := tx.Where("season_id = ? AND active = ?", seasonID, true).Limit(1).Find(&active)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return DefaultScoringRules(), nil
+	}
+	return &active, nil
+}
+
+func setActiveScoringRules(rules *ScoringRuleSet) {
+	activeScoringRulesMutex.Lock()
+	activeScoringRules = rules
+	activeScoringRulesMutex.Unlock()
+}
+
+// MigrateScoringRuleSets numbers rule set versions per season, replacing the index that kept versions
+// unique across all seasons
+func MigrateScoringRuleSets() error {
+	migrator := db.ORM.Migrator()
+	for _, index := range []string{"idx_scoring_rule_sets_version", "idx_scoring_rule_sets_season_id"} {
+		if migrator.HasIndex(&ScoringRuleSet{}, index) {
+			if err := migrator.DropIndex(&ScoringRuleSet{}, index); err != nil {
+				return err
+			}
+		}
+	}
+	return db.ORM.AutoMigrate(&ScoringRuleSet{})
+}
+
+// AddScoringRuleSet creates a new inactive rule set for the active season with the next version number
+func AddScoringRuleSet(rules *ScoringRuleSet) error {
+	if err := rules.validate(); err != nil {
+		return err
+	}
+
+	return db.ORM.Transaction(func(tx *gorm.DB) error {
+		seasonID, err := getActiveSeasonID(tx)
+		if err != nil {
+			return err
+		}
+
+		var version int
+		result := tx.Unscoped().Model(&ScoringRuleSet{}).Where("season_id = ?", seasonID).Select("COALESCE(MAX(version), 0)").Scan(&version)
+		if result.Error != nil {
+			return result.Error
+		}
+
+		rules.ID = 0
+		rules.SeasonID = seasonID
+		rules.Version = version + 1
+		rules.Active = false
+		rules.ActivatedAt = nil
+		return tx.Create(rules).Error
+	})
+}
+
+func GetScoringRuleSetByID(id string) (*ScoringRuleSet, error) {
+	var rules *ScoringRuleSet
+	result := db.ORM.First(&rules, id)
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return rules, nil
+}
+
+func GetAllScoringRuleSets(seasonID uint) ([]*ScoringRuleSet, error) {
+	var rules []*ScoringRuleSet
+
+	result := db.ORM.Where("season_id = ?", seasonID).Order("version desc").Find(&rules)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return rules, nil
+}
+
+// UpdateScoringRuleSet saves changes to a rule set that has never been activated
+func UpdateScoringRuleSet(rules *ScoringRuleSet) error {
+	if rules.ActivatedAt != nil {
+		return fmt.Errorf("rule set version %d has been activated and can no longer be edited, create a new version instead", rules.Version)
+	}
+	if err := rules.validate(); err != nil {
+		return err
+	}
+	if err := checkSeasonWritable(db.ORM, rules.SeasonID); err != nil {
+		return err
+	}
+
+	rules.Active = false
+	result := db.ORM.Save(&rules)
+	return result.Error
+}
+
+// DeleteScoringRuleSetByID deletes a rule set that has never been activated
+func DeleteScoringRuleSetByID(id string) error {
+	rules, err := GetScoringRuleSetByID(id)
+	if err != nil {
+		return err
+	}
+	if rules.ActivatedAt != nil {
+		return fmt.Errorf("rule set version %d has been activated and cannot be deleted", rules.Version)
+	}
+
+	result := db.ORM.Delete(&rules)
+	return result.Error
+}
+
+// PreviewScoringRuleSet calculates the points and value of every player of the rule set's season under
+// the given rule set without saving them
+func PreviewScoringRuleSet(rules *ScoringRuleSet) ([]*PlayerScoringPreview, error) {
+	players, err := GetAllPlayers(rules.SeasonID)
+	if err != nil {
+		return nil, err
+	}
+
+	var previews []*PlayerScoringPreview
+	for _, player := range players {
+		preview := &PlayerScoringPreview{
+			ID:            player.ID,
+			Name:          player.Name,
+			CurrentPoints: intValue(player.Points),
+			CurrentValue:  intValue(player.Value),
+		}
+
+		CalculatePlayerStatsWithRules(player, rules)
+		preview.NewPoints = intValue(player.Points)
+		preview.NewValue = intValue(player.Value)
+		preview.PointsChange = preview.NewPoints - preview.CurrentPoints
+		preview.ValueChange = preview.NewValue - preview.CurrentValue
+
+		previews = append(previews, preview)
+	}
+	return previews, nil
+}
+
+// ActivateScoringRuleSet makes the rule set the active one of the active season and recalculates
+// every player of the season with it
+func ActivateScoringRuleSet(id string) (*ScoringRuleSet, error) {
+	rules, err := GetScoringRuleSetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := rules.validate(); err != nil {
+		return nil, err
+	}
+	season, err := GetActiveSeason()
+	if err != nil {
+		return nil, err
+	}
+	if rules.SeasonID != season.ID {
+		return nil, fmt.Errorf("rule set version %d does not belong to the active season", rules.Version)
+	}
+
+	now := time.Now()
+	err = db.ORM.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&ScoringRuleSet{}).Where("season_id = ? AND active = ?", season.ID, true).Update("active", false).Error; err != nil {
+			return err
+		}
+
+		rules.Active = true
+		if rules.ActivatedAt == nil {
+			rules.ActivatedAt = &now
+		}
+		return tx.Save(rules).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	setActiveScoringRules(rules)
+
+	players, err := GetAllPlayers(season.ID)
+	if err != nil {
+		return nil, err
+	}
+	err = SavePlayers(players)
+	if err != nil {
+		return nil, err
+	}
+
+	QueueAllTeamsRecompute()
+	return rules, nil
+}
+
+func intValue(value *int) int {
+	if value == nil {
+		return 0
+	}
+	return *value
+}
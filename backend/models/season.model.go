@@ -0,0 +1,207 @@
+// models/season.model.go
+package models
+
+import (
+	"errors"
+	"fmt"
+	"go-orm-template/db"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const DefaultSeasonBudget = 9000000
+
+var ErrSeasonReadOnly = errors.New("the season has ended and is read-only")
+
+// Season scopes players, teams, budgets, scoring rules and leaderboards. Only one season is active at
+// a time; activating another season ends the current one, which is then kept read-only for history.
+type Season struct {
+	GormModel
+	Name      string     `json:"name" gorm:"uniqueIndex;not null"`
+	Active    bool       `json:"active"`
+	Budget    int        `json:"budget"` // Budget of every team in the season
+	StartedAt *time.Time `json:"started_at"`
+	EndedAt   *time.Time `json:"ended_at"`
+}
+
+// seasonScoped lists the models that belong to a season, used to backfill rows created before seasons existed
+var seasonScoped = []interface{}{&Player{}, &Team{}, &Match{}, &ScoringRuleSet{}, &TeamRoundSnapshot{}, &League{}}
+
+func (season *Season) checkWritable() error {
+	if season.EndedAt != nil {
+		return ErrSeasonReadOnly
+	}
+	return nil
+}
+
+// getActiveSeason returns the active season, which is the one all changes are made in
+func getActiveSeason(tx *gorm.DB) (*Season, error) {
+	var season Season
+	result := tx.Where("active = ?", true).Limit(1).Find(&season)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, fmt.Errorf("no season is active")
+	}
+	return &season, nil
+}
+
+func GetActiveSeason() (*Season, error) {
+	return getActiveSeason(db.ORM)
+}
+
+func getActiveSeasonID(tx *gorm.DB) (uint, error) {
+	season, err := getActiveSeason(tx)
+	if err != nil {
+		return 0, err
+	}
+	return season.ID, nil
+}
+
+// CheckSeasonWritable returns ErrSeasonReadOnly when the season has ended
+func CheckSeasonWritable(seasonID uint) error {
+	return checkSeasonWritable(db.ORM, seasonID)
+}
+
+func checkSeasonWritable(tx *gorm.DB, seasonID uint) error {
+	var season Season
+	result := tx.First(&season, seasonID)
+	if result.Error != nil {
+		return result.Error
+	}
+	return season.checkWritable()
+}
+
+func GetSeasonByID(id string) (*Season, error) {
+	var season *Season
+	result := db.ORM.First(&season, id)
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return season, nil
+}
+
+// ResolveSeason returns the season with the given ID, or the active season when no ID is given
+func ResolveSeason(id string) (*Season, error) {
+	if id == "" {
+		return GetActiveSeason()
+	}
+	return GetSeasonByID(id)
+}
+
+func GetAllSeasons() ([]*Season, error) {
+	var seasons []*Season
+
+	result := db.ORM.Order("id desc").Find(&seasons)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return seasons, nil
+}
+
+// AddSeason creates a new inactive season, it starts once activated
+func AddSeason(season *Season) error {
+	season.Name = strings.TrimSpace(season.Name)
+	if season.Name == "" {
+		return fmt.Errorf("season name is required")
+	}
+	if season.Budget == 0 {
+		season.Budget = DefaultSeasonBudget
+	}
+	if season.Budget < 0 {
+		return fmt.Errorf("season budget cannot be negative")
+	}
+
+	season.ID = 0
+	season.Active = false
+	season.StartedAt = nil
+	season.EndedAt = nil
+	result := db.ORM.Create(season)
+	return result.Error
+}
+
+// UpdateSeason saves changes to the name and budget of a season that has not ended
+func UpdateSeason(season *Season) error {
+	if err := season.checkWritable(); err != nil {
+		return err
+	}
+	if season.Budget <= 0 {
+		return fmt.Errorf("season budget must be greater than 0")
+	}
+
+	result := db.ORM.Model(season).Updates(map[string]interface{}{"name": season.Name, "budget": season.Budget})
+	return result.Error
+}
+
+// ActivateSeason starts the season and ends the one that was active, making it read-only
+func ActivateSeason(id string) (*Season, error) {
+	season, err := GetSeasonByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if season.Active {
+		return season, nil
+	}
+	if err := season.checkWritable(); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	err = db.ORM.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&Season{}).Where("active = ?", true).Updates(map[string]interface{}{"active": false, "ended_at": now})
+		if result.Error != nil {
+			return result.Error
+		}
+
+		season.Active = true
+		season.StartedAt = &now
+		return tx.Save(season).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// The new season starts with its own scoring rules
+	setActiveScoringRules(nil)
+	return season, nil
+}
+
+// MigrateSeasons creates the first season when there is none and moves the rows created before
+// seasons existed into it. Teams were unique per user, they are now unique per user and season.
+func MigrateSeasons() error {
+	var count int64
+	if err := db.ORM.Model(&Season{}).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		now := time.Now()
+		season := &Season{Name: "Season 1", Active: true, Budget: DefaultSeasonBudget, StartedAt: &now}
+		if err := db.ORM.Create(season).Error; err != nil {
+			return err
+		}
+	}
+
+	var first Season
+	if err := db.ORM.Order("id asc").First(&first).Error; err != nil {
+		return err
+	}
+	for _, model := range seasonScoped {
+		result := db.ORM.Unscoped().Model(model).Where("season_id = ? OR season_id IS NULL", 0).Update("season_id", first.ID)
+		if result.Error != nil {
+			return result.Error
+		}
+	}
+
+	for _, constraint := range []string{"uni_teams_user_id", "teams_user_id_key"} {
+		if db.ORM.Migrator().HasConstraint(&Team{}, constraint) {
+			if err := db.ORM.Migrator().DropConstraint(&Team{}, constraint); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
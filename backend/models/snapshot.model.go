@@ -0,0 +1,54 @@
+// models/snapshot.model.go
+package models
+
+import (
+	"go-orm-template/db"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// PlayerStatSnapshot records a player's points and value each time they change
+type PlayerStatSnapshot struct {
+	GormModel
+	PlayerID uint `json:"player_id" gorm:"not null;index"`
+	Points   int  `json:"points"`
+	Value    int  `json:"value"`
+}
+
+type PlayerStatSnapshotForUser struct {
+	Value     int       `json:"value"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// recordPlayerStatSnapshot adds a snapshot when the player's points or value differ from the latest one
+func recordPlayerStatSnapshot(tx *gorm.DB, player *Player) error {
+	var latest PlayerStatSnapshot
+	result := tx.Where("player_id = ?", player.ID).Order("id desc").Limit(1).Find(&latest)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	points, value := intValue(player.Points), intValue(player.Value)
+	if result.RowsAffected > 0 && latest.Points == points && latest.Value == value {
+		return nil
+	}
+
+	snapshot := &PlayerStatSnapshot{
+		PlayerID: player.ID,
+		Points:   points,
+		Value:    value,
+	}
+	return tx.Create(snapshot).Error
+}
+
+// GetPlayerStatHistory returns the snapshots of a player in chronological order
+func GetPlayerStatHistory(playerID string) ([]*PlayerStatSnapshot, error) {
+	var snapshots []*PlayerStatSnapshot
+	result := db.ORM.Where("player_id = ?", playerID).Order("created_at asc, id asc").Find(&snapshots)
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return snapshots, nil
+}
@@ -0,0 +1,162 @@
+// models/squad.model.go
+package models
+
+import (
+	"fmt"
+	"go-orm-template/config"
+	"sort"
+	"strings"
+)
+
+const SquadSize = 11
+
+// SquadRuleViolation describes a single composition rule that a squad breaks
+type SquadRuleViolation struct {
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+	Limit   int    `json:"limit"`
+	Actual  int    `json:"actual"`
+}
+
+// SquadCompositionError is returned when a squad breaks one or more composition rules
+type SquadCompositionError struct {
+	Violations []SquadRuleViolation
+}
+
+func (e *SquadCompositionError) Error() string {
+	var messages []string
+	for _, violation := range e.Violations {
+		messages = append(messages, violation.Message)
+	}
+	return "squad composition rules violated: " + strings.Join(messages, "; ")
+}
+
+type CategoryRule struct {
+	Category string `json:"category"`
+	Min      int    `json:"min"`
+	Max      int    `json:"max"`
+}
+
+type SquadRules struct {
+	SquadSize               int            `json:"squad_size"`
+	Categories              []CategoryRule `json:"categories"`
+	MaxPlayersPerUniversity int            `json:"max_players_per_university"`
+}
+
+// GetSquadRules returns the configured composition rules
+func GetSquadRules() *SquadRules {
+	return &SquadRules{
+		SquadSize: SquadSize,
+		Categories: []CategoryRule{
+			{Category: "Batsman", Min: config.MinBatsmen, Max: config.MaxBatsmen},
+			{Category: "Bowler", Min: config.MinBowlers, Max: config.MaxBowlers},
+			{Category: "All-Rounder", Min: config.MinAllRounders, Max: config.MaxAllRounders},
+		},
+		MaxPlayersPerUniversity: config.MaxPlayersPerUniversity,
+	}
+}
+
+// substitutedCount returns the most players of a group the starting lineup can field once bench players
+// of the group replace starters outside it who did not play
+func substitutedCount(starters []*Player, bench []*Player, inGroup func(player *Player) bool) int {
+	count, substitutes := 0, 0
+	for _, player := range starters {
+		if inGroup(player) {
+			count++
+		}
+	}
+	for _, player := range bench {
+		if inGroup(player) {
+			substitutes++
+		}
+	}
+	if others := len(starters) - count; substitutes > others {
+		substitutes = others
+	}
+	return count + substitutes
+}
+
+// maxViolationMessage describes a broken maximum, naming the bench when only substitutions break it
+func maxViolationMessage(limit int, group string, count int, starting int) string {
+	message := fmt.Sprintf("at most %d players %s are allowed, got %d", limit, group, count)
+	if starting <= limit {
+		message += " after substitutions from the bench"
+	}
+	return message
+}
+
+// validateSquadComposition checks the squad against the composition rules. Maximums apply to the
+// starting players and to every lineup that substitutions from the bench can produce, while minimums
+// only apply to the starting players once the squad is complete.
+func validateSquadComposition(starters []*Player, bench []*Player) error {
+	rules := GetSquadRules()
+	complete := len(starters) == rules.SquadSize
+
+	categories := make(map[string]int)
+	universities := make(map[string]int)
+	for _, player := range starters {
+		categories[player.Category]++
+		universities[player.University]++
+	}
+
+	var violations []SquadRuleViolation
+	for _, rule := range rules.Categories {
+		count := categories[rule.Category]
+		category := rule.Category
+		worst := substitutedCount(starters, bench, func(player *Player) bool { return player.Category == category })
+		if rule.Max > 0 && worst > rule.Max {
+			violations = append(violations, SquadRuleViolation{
+				Rule:    "max_" + ruleName(rule.Category),
+				Message: maxViolationMessage(rule.Max, "of category "+rule.Category, worst, count),
+				Limit:   rule.Max,
+				Actual:  worst,
+			})
+		}
+		if complete && count < rule.Min {
+			violations = append(violations, SquadRuleViolation{
+				Rule:    "min_" + ruleName(rule.Category),
+				Message: fmt.Sprintf("at least %d players of category %s are required, got %d", rule.Min, rule.Category, count),
+				Limit:   rule.Min,
+				Actual:  count,
+			})
+		}
+	}
+
+	if rules.MaxPlayersPerUniversity > 0 {
+		var names []string
+		for _, player := range bench {
+			if _, ok := universities[player.University]; !ok {
+				universities[player.University] = 0
+			}
+		}
+		for university := range universities {
+			names = append(names, university)
+		}
+		sort.Strings(names)
+		for _, university := range names {
+			worst := substitutedCount(starters, bench, func(player *Player) bool { return player.University == university })
+			if worst > rules.MaxPlayersPerUniversity {
+				violations = append(violations, SquadRuleViolation{
+					Rule:    "max_players_per_university",
+					Message: maxViolationMessage(rules.MaxPlayersPerUniversity, "from "+university, worst, universities[university]),
+					Limit:   rules.MaxPlayersPerUniversity,
+					Actual:  worst,
+				})
+			}
+		}
+	}
+
+	if len(violations) > 0 {
+		return &SquadCompositionError{Violations: violations}
+	}
+	return nil
+}
+
+// ruleName converts a category such as All-Rounder to all_rounders
+func ruleName(category string) string {
+	name := strings.ToLower(strings.ReplaceAll(category, "-", "_"))
+	if name == "batsman" {
+		return "batsmen"
+	}
+	return name + "s"
+}
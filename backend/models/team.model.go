@@ -3,18 +3,50 @@ package models
 
 import (
 	"fmt"
+	"go-orm-template/config"
 	"go-orm-template/db"
+	"math"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type Team struct {
 	GormModel
-	Name    string    `json:"name"`
-	UserID  uint      `json:"user_id" gorm:"not null;unique"` // Changed from Owner to UserId and made unique
-	User    *User     `json:"user" gorm:"foreignKey:UserID"`
-	Players []*Player `json:"players" gorm:"many2many:team_players;"`
-	Points  int       `json:"points"`
-	Value   int       `json:"value"`
-	Full    bool      `json:"full"`
+	SeasonID uint           `json:"season_id" gorm:"uniqueIndex:idx_team_season_user"`
+	Name     string         `json:"name"`
+	UserID   uint           `json:"user_id" gorm:"not null;uniqueIndex:idx_team_season_user"` // A user has one team per season
+	User     *User          `json:"user" gorm:"foreignKey:UserID"`
+	Players  []*Player      `json:"players" gorm:"many2many:team_players;"`
+	Squad    []*SquadPlayer `json:"squad,omitempty" gorm:"foreignKey:TeamID"`
+	Points   int            `json:"points"`
+	Value    int            `json:"value"`
+	Full     bool           `json:"full"`
+
+	TransferPenalty      int  `json:"transfer_penalty"`       // Points lost to transfers over the free limit, deducted from season totals
+	InitialSquadSelected bool `json:"initial_squad_selected"` // Set once the squad is first full, transfers count from then on
+	SellingProfit        int  `json:"selling_profit"`         // Profit or loss on the players sold, added to the season budget
+
+	CaptainID     *uint `json:"captain_id"`
+	ViceCaptainID *uint `json:"vice_captain_id"`
+}
+
+const (
+	CaptainMultiplier     = 2.0
+	ViceCaptainMultiplier = 1.5
+)
+
+// pointsMultiplier returns how many times a player's points count for the team
+func (team *Team) pointsMultiplier(playerID uint) float64 {
+	if team.CaptainID != nil && *team.CaptainID == playerID {
+		return CaptainMultiplier
+	}
+	if team.ViceCaptainID != nil && *team.ViceCaptainID == playerID {
+		return ViceCaptainMultiplier
+	}
+	return 1
 }
 
 // convertPlayers converts []*Player to []Player
@@ -27,117 +59,539 @@ func convertPlayers(players []*Player) []Player {
 }
 
 type TeamPlayersView struct {
-	TeamName string   `json:"team_name"`
-	Players  []Player `json:"players"`
-	IsFound  bool     `json:"is_found"`
-	Value    int      `json:"value"`
-	Points   int      `json:"points"`
+	ID            uint               `json:"id"`
+	TeamName      string             `json:"team_name"`
+	Players       []Player           `json:"players"`
+	Bench         []Player           `json:"bench"`
+	Prices        []SquadPlayerPrice `json:"prices"`
+	IsFound       bool               `json:"is_found"`
+	Value         int                `json:"value"`
+	SellingValue  int                `json:"selling_value"` // What the squad would sell for at the current prices
+	Points        int                `json:"points"`
+	Full          bool               `json:"full"`
+	CaptainID     *uint              `json:"captain_id"`
+	ViceCaptainID *uint              `json:"vice_captain_id"`
+	Previous      *TeamPlayersView   `json:"-"` // The team before the change that returned this view
+}
+
+// TeamForUser is what users see of other users' teams: the standing without the squad, captains
+// and budget
+type TeamForUser struct {
+	ID     uint   `json:"id"`
+	Name   string `json:"name"`
+	Points int    `json:"points"`
+	Value  int    `json:"value"`
+	Full   bool   `json:"full"`
+}
+
+// NewTeamForUser returns the fields of the team that other users may see
+func NewTeamForUser(team *Team) TeamForUser {
+	return TeamForUser{ID: team.ID, Name: team.Name, Points: team.Points, Value: team.Value, Full: team.Full}
+}
+
+// ForUser returns the fields of the viewed team that other users may see
+func (view *TeamPlayersView) ForUser() TeamForUser {
+	return TeamForUser{ID: view.ID, Name: view.TeamName, Points: view.Points, Value: view.Value, Full: view.Full}
+}
+
+// SquadPlayerPrice is what the team paid for a squad player and would get for selling them now
+type SquadPlayerPrice struct {
+	PlayerID      uint `json:"player_id"`
+	PurchasePrice int  `json:"purchase_price"`
+	SellingPrice  int  `json:"selling_price"`
+}
+
+type TeamCaptains struct {
+	UserID        uint  `json:"user_id"`
+	CaptainID     *uint `json:"captain_id"`
+	ViceCaptainID *uint `json:"vice_captain_id"`
 }
 
 type TeamPlayers struct {
-	UserID    uint   `json:"user_id"`
-	PlayerIDs []uint `json:"player_ids"`
+	UserID         uint   `json:"user_id"`
+	PlayerIDs      []uint `json:"player_ids"`
+	BenchPlayerIDs []uint `json:"bench_player_ids"` // In substitution order
+	Override       bool   `json:"-"`                // Set for admins to change a squad after the round deadline
+}
+
+// SquadPlayer is a row of the team_players join table, the only link between teams and players. Besides
+// the player's place in the squad it records when and at what price the team bought the player.
+type SquadPlayer struct {
+	TeamID        uint      `json:"team_id" gorm:"primaryKey"`
+	PlayerID      uint      `json:"player_id" gorm:"primaryKey;index"`
+	Bench         bool      `json:"bench"`
+	BenchOrder    int       `json:"bench_order"`
+	AddedAt       time.Time `json:"added_at" gorm:"not null;default:CURRENT_TIMESTAMP"`
+	PurchasePrice int       `json:"purchase_price" gorm:"not null;default:0"`
+}
+
+func (SquadPlayer) TableName() string {
+	return "team_players"
+}
+
+// SetupJoinTables registers SquadPlayer as the join model of Team.Players and Player.Teams, so both
+// sides of the relation read and write team_players with its extra columns
+func SetupJoinTables() error {
+	if err := db.ORM.SetupJoinTable(&Team{}, "Players", &SquadPlayer{}); err != nil {
+		return err
+	}
+	return db.ORM.SetupJoinTable(&Player{}, "Teams", &SquadPlayer{})
+}
+
+// MigrateSquadPlayers makes team_players the only join table between teams and players. Rows from before the
+// purchase columns existed are given the price and date of the transfer that brought the player in, or the
+// player's current value. Player.Teams used to point at player_teams, which assignments never wrote; its rows
+// are merged in for teams without a squad before it is dropped.
+func MigrateSquadPlayers() error {
+	backfill := db.ORM.Migrator().HasTable(&SquadPlayer{}) && !db.ORM.Migrator().HasColumn(&SquadPlayer{}, "PurchasePrice")
+	if err := db.ORM.AutoMigrate(&SquadPlayer{}); err != nil {
+		return err
+	}
+
+	if backfill {
+		result := db.ORM.Exec(`UPDATE team_players SET purchase_price = COALESCE(players.value, 0)
+			FROM players WHERE players.id = team_players.player_id`)
+		if result.Error != nil {
+			return result.Error
+		}
+		if db.ORM.Migrator().HasTable(&Transfer{}) {
+			result = db.ORM.Exec(`UPDATE team_players SET purchase_price = bought.price, added_at = bought.created_at
+				FROM (SELECT DISTINCT ON (team_id, player_id) team_id, player_id, price, created_at FROM transfers
+					WHERE direction = ? AND deleted_at IS NULL ORDER BY team_id, player_id, created_at DESC) AS bought
+				WHERE bought.team_id = team_players.team_id AND bought.player_id = team_players.player_id`, TransferIn)
+			if result.Error != nil {
+				return result.Error
+			}
+		}
+	}
+
+	if db.ORM.Migrator().HasTable("player_teams") {
+		result := db.ORM.Exec(`INSERT INTO team_players (team_id, player_id, purchase_price)
+			SELECT DISTINCT player_teams.team_id, player_teams.player_id, COALESCE(players.value, 0) FROM player_teams
+			JOIN teams ON teams.id = player_teams.team_id
+			JOIN players ON players.id = player_teams.player_id
+			WHERE NOT EXISTS (SELECT 1 FROM team_players WHERE team_players.team_id = player_teams.team_id)
+			ON CONFLICT DO NOTHING`)
+		if result.Error != nil {
+			return result.Error
+		}
+		fmt.Printf("Merged %d rows from player_teams into team_players\n", result.RowsAffected)
+		if err := db.ORM.Migrator().DropTable("player_teams"); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
-// AddTeam creates a new team record in the database
+// newSquadRows builds the squad of the team, players that stay in the squad keep the date and price
+// they were bought at and new players are bought at their current value
+func newSquadRows(team *Team, starters []*Player, bench []*Player) []*SquadPlayer {
+	previous := make(map[uint]*SquadPlayer)
+	for _, row := range team.Squad {
+		previous[row.PlayerID] = row
+	}
+
+	now := time.Now()
+	newRow := func(player *Player) *SquadPlayer {
+		row := &SquadPlayer{TeamID: team.ID, PlayerID: player.ID, AddedAt: now, PurchasePrice: intValue(player.Value)}
+		if kept, ok := previous[player.ID]; ok {
+			row.AddedAt = kept.AddedAt
+			row.PurchasePrice = kept.PurchasePrice
+		}
+		return row
+	}
+
+	var squad []*SquadPlayer
+	for _, player := range starters {
+		squad = append(squad, newRow(player))
+	}
+	for i, player := range bench {
+		row := newRow(player)
+		row.Bench = true
+		row.BenchOrder = i + 1
+		squad = append(squad, row)
+	}
+	return squad
+}
+
+// AddTeam creates a new team record in the active season
 func AddTeam(team *Team) error {
+	seasonID, err := getActiveSeasonID(db.ORM)
+	if err != nil {
+		return err
+	}
+	team.SeasonID = seasonID
 	result := db.ORM.Create(&team)
 	return result.Error
 }
 
-// AddPlayersToTeamByUserID adds players to a team by user ID and player IDs
-func AssignPlayersToTeamByUserID(teamPlayers TeamPlayers) error {
-	var team Team
-	result := db.ORM.Preload("User").Where("user_id = ?", teamPlayers.UserID).First(&team)
+// splitSquad returns the starting players and the bench players in substitution order
+func (team *Team) splitSquad() ([]*Player, []*Player) {
+	rows := make(map[uint]*SquadPlayer)
+	for _, row := range team.Squad {
+		rows[row.PlayerID] = row
+	}
+
+	var starters, bench []*Player
+	for _, player := range team.Players {
+		if row, ok := rows[player.ID]; ok && row.Bench {
+			bench = append(bench, player)
+		} else {
+			starters = append(starters, player)
+		}
+	}
+	sort.SliceStable(bench, func(i, j int) bool {
+		return rows[bench[i].ID].BenchOrder < rows[bench[j].ID].BenchOrder
+	})
+	return starters, bench
+}
+
+// findPlayersInOrder loads the season's players with the given IDs in the same order, rejecting unknown and repeated IDs
+func findPlayersInOrder(tx *gorm.DB, seasonID uint, ids []uint) ([]*Player, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	var found []*Player
+	result := tx.Where("season_id = ?", seasonID).Find(&found, ids)
 	if result.Error != nil {
-		return result.Error
+		return nil, result.Error
+	}
+	byID := make(map[uint]*Player)
+	for _, player := range found {
+		byID[player.ID] = player
 	}
 
-	if len(teamPlayers.PlayerIDs) == 0 {
-		err := db.ORM.Model(&team).Association("Players").Clear()
-		go updateTeamPointsAndValue(&team)
-		return err
+	players := make([]*Player, 0, len(ids))
+	seen := make(map[uint]bool)
+	for _, id := range ids {
+		player, ok := byID[id]
+		if !ok {
+			return nil, fmt.Errorf("player %d not found", id)
+		}
+		if seen[id] {
+			return nil, fmt.Errorf("player %d is selected more than once", id)
+		}
+		seen[id] = true
+		players = append(players, player)
 	}
+	return players, nil
+}
 
-	var players []*Player
-	result = db.ORM.Find(&players, teamPlayers.PlayerIDs)
+// lockTeam loads the team matching the conditions with its squad, holding a row lock on the team until
+// the transaction ends so concurrent changes to the same team are applied one after the other
+func lockTeam(tx *gorm.DB, team *Team, query interface{}, args ...interface{}) error {
+	result := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where(query, args...).First(team)
 	if result.Error != nil {
 		return result.Error
 	}
+	if err := tx.Model(team).Association("Players").Find(&team.Players); err != nil {
+		return err
+	}
+	return tx.Where("team_id = ?", team.ID).Find(&team.Squad).Error
+}
 
-	// Check if adding the new players would exceed the maximum limit of 11 players
-	if len(players) > 11 {
-		return fmt.Errorf("maximum limit of 11 players per team exceeded by %d", len(players)-11)
+// AssignPlayersToTeamByUserID replaces the squad of the user's team in the active season and returns the
+// committed team. The team row is locked and the selected players are locked against price changes for
+// the whole assignment, so the budget check, transfers and recalculated points and value are consistent.
+func AssignPlayersToTeamByUserID(teamPlayers TeamPlayers) (*TeamPlayersView, error) {
+	var team Team
+	var previous *TeamPlayersView
+	err := db.ORM.Transaction(func(tx *gorm.DB) error {
+		season, err := getActiveSeason(tx)
+		if err != nil {
+			return err
+		}
+
+		if err := lockTeam(tx, &team, "user_id = ? AND season_id = ?", teamPlayers.UserID, season.ID); err != nil {
+			return err
+		}
+		previous = newTeamPlayersView(&team)
+
+		if !teamPlayers.Override {
+			if err := checkTeamLock(tx); err != nil {
+				return err
+			}
+		}
+
+		ids := append(append([]uint{}, teamPlayers.PlayerIDs...), teamPlayers.BenchPlayerIDs...)
+		players, err := findPlayersInOrder(tx.Clauses(clause.Locking{Strength: "SHARE"}), season.ID, ids)
+		if err != nil {
+			return err
+		}
+		starters := players[:len(teamPlayers.PlayerIDs)]
+		bench := players[len(teamPlayers.PlayerIDs):]
+
+		// Check if adding the new players would exceed the maximum limit of 11 players
+		if len(starters) > SquadSize {
+			return fmt.Errorf("maximum limit of %d players per team exceeded by %d", SquadSize, len(starters)-SquadSize)
+		}
+		if len(bench) > config.BenchSize {
+			return fmt.Errorf("maximum limit of %d bench players exceeded by %d", config.BenchSize, len(bench)-config.BenchSize)
+		}
+		if len(bench) > 0 && len(starters) < SquadSize {
+			return fmt.Errorf("the starting %d players must be selected before the bench", SquadSize)
+		}
+
+		if err := validateSquadComposition(starters, bench); err != nil {
+			return err
+		}
+
+		// Players kept in the squad cost what they were bought at and new players their current value,
+		// the profit or loss on the players sold so far is added to the season's budget
+		squad := newSquadRows(&team, starters, bench)
+		totalPrice := 0
+		for _, row := range squad {
+			totalPrice += row.PurchasePrice
+		}
+		profit := team.SellingProfit + team.salesProfit(players)
+		if totalPrice > season.Budget+profit {
+			return fmt.Errorf("the total price of the players exceeds the available budget of %d", season.Budget+profit)
+		}
+
+		penalty, err := recordTransfers(tx, &team, team.Players, players)
+		if err != nil {
+			return err
+		}
+
+		// Replace the team's squad
+		if err := tx.Where("team_id = ?", team.ID).Delete(&SquadPlayer{}).Error; err != nil {
+			return err
+		}
+		if len(squad) > 0 {
+			if err := tx.Create(&squad).Error; err != nil {
+				return err
+			}
+		}
+		team.Players = players
+		team.Squad = squad
+
+		team.TransferPenalty += penalty
+		team.SellingProfit = profit
+		team.InitialSquadSelected = team.InitialSquadSelected || len(starters) == SquadSize
+		if !containsPlayer(players, team.CaptainID) {
+			team.CaptainID = nil
+		}
+		if !containsPlayer(players, team.ViceCaptainID) {
+			team.ViceCaptainID = nil
+		}
+		result := tx.Model(&team).Updates(map[string]interface{}{
+			"transfer_penalty":       team.TransferPenalty,
+			"selling_profit":         team.SellingProfit,
+			"initial_squad_selected": team.InitialSquadSelected,
+			"captain_id":             team.CaptainID,
+			"vice_captain_id":        team.ViceCaptainID,
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+		return updateTeamPointsAndValue(tx, &team)
+	})
+	if err != nil {
+		return nil, err
 	}
+	view := newTeamPlayersView(&team)
+	view.Previous = previous
+	return view, nil
+}
 
-	// Calculate the total value of all players in the team
-	totalValue := 0
+// SetTeamCaptains nominates the captain and vice-captain of the user's team of the active season from its
+// squad and returns the committed team
+func SetTeamCaptains(teamCaptains TeamCaptains) (*TeamPlayersView, error) {
+	var team Team
+	var previous *TeamPlayersView
+	err := db.ORM.Transaction(func(tx *gorm.DB) error {
+		seasonID, err := getActiveSeasonID(tx)
+		if err != nil {
+			return err
+		}
+
+		if err := lockTeam(tx, &team, "user_id = ? AND season_id = ?", teamCaptains.UserID, seasonID); err != nil {
+			return err
+		}
+		previous = newTeamPlayersView(&team)
+
+		if err := checkTeamLock(tx); err != nil {
+			return err
+		}
+
+		if teamCaptains.CaptainID != nil && !containsPlayer(team.Players, teamCaptains.CaptainID) {
+			return fmt.Errorf("captain must be a player in the team")
+		}
+		if teamCaptains.ViceCaptainID != nil && !containsPlayer(team.Players, teamCaptains.ViceCaptainID) {
+			return fmt.Errorf("vice-captain must be a player in the team")
+		}
+		if teamCaptains.CaptainID != nil && teamCaptains.ViceCaptainID != nil && *teamCaptains.CaptainID == *teamCaptains.ViceCaptainID {
+			return fmt.Errorf("captain and vice-captain must be different players")
+		}
+
+		team.CaptainID = teamCaptains.CaptainID
+		team.ViceCaptainID = teamCaptains.ViceCaptainID
+		result := tx.Model(&team).Updates(map[string]interface{}{
+			"captain_id":      team.CaptainID,
+			"vice_captain_id": team.ViceCaptainID,
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+		return updateTeamPointsAndValue(tx, &team)
+	})
+	if err != nil {
+		return nil, err
+	}
+	view := newTeamPlayersView(&team)
+	view.Previous = previous
+	return view, nil
+}
+
+// containsPlayer reports whether the player ID is set and in the list of players
+func containsPlayer(players []*Player, playerID *uint) bool {
+	if playerID == nil {
+		return false
+	}
 	for _, player := range players {
-		totalValue += *player.Value
+		if player.ID == *playerID {
+			return true
+		}
 	}
+	return false
+}
+
+// teamPointsSource holds what a season's teams are scored from, so a batch of teams loads it once.
+// roundPoints is nil for seasons without match data, whose teams score from the players' season totals.
+type teamPointsSource struct {
+	last        int
+	roundPoints map[uint]int
+}
 
-	// Check if the total value exceeds the user's budget
-	if totalValue > team.User.Budget { // Assuming User struct has a Budget field
-		return fmt.Errorf("the total value of the players exceeds the user's budget")
+// loadTeamPointsSource loads the last closed round of the season and, when its stats come from
+// matches, the player points of the round in progress
+func loadTeamPointsSource(tx *gorm.DB, seasonID uint) (*teamPointsSource, error) {
+	last, err := getLastSnapshotRound(tx, seasonID)
+	if err != nil {
+		return nil, err
+	}
+	source := &teamPointsSource{last: last}
+
+	hasMatchData, err := seasonHasMatchData(tx, seasonID)
+	if err != nil || !hasMatchData {
+		return source, err
 	}
+	source.roundPoints, err = getPlayerRoundPoints(tx, seasonID, last+1)
+	return source, err
+}
 
-	// Append players to the team's Players association
-	err := db.ORM.Model(&team).Association("Players").Replace(players)
+// updateTeamPointsAndValue recalculates the team from its loaded squad and saves only the derived
+// columns, so it never overwrites other changes to the team
+func updateTeamPointsAndValue(tx *gorm.DB, team *Team) error {
+	source, err := loadTeamPointsSource(tx, team.SeasonID)
 	if err != nil {
 		return err
 	}
-	team.Full = len(players) == 11
-	team.Players = players
-	go updateTeamPointsAndValue(&team)
-	return nil
+	return saveTeamPointsAndValue(tx, team, source)
 }
 
-func updateTeamPointsAndValue(team *Team) {
+// saveTeamPointsAndValue recalculates the team's points from the source. In seasons with match data the
+// points are the scores of the closed rounds plus the score of the round in progress, so captains only
+// multiply the points scored in the rounds they captained and each round deducts its own transfer
+// penalties. Otherwise they are the starters' season points times their multipliers, less the team's
+// transfer penalty.
+func saveTeamPointsAndValue(tx *gorm.DB, team *Team, source *teamPointsSource) error {
 	totalValue := 0
-	totalPoints := 0
 	for _, player := range team.Players {
-		totalValue += *player.Value
-		totalPoints += *player.Points
+		totalValue += intValue(player.Value)
+	}
+	starters, _ := team.splitSquad()
+
+	if source.roundPoints != nil {
+		closedPoints, err := getClosedRoundsPoints(tx, team.ID, source.last)
+		if err != nil {
+			return err
+		}
+		scores, err := scoreTeamsWithPoints(tx, source.last+1, []*Team{team}, source.roundPoints)
+		if err != nil {
+			return err
+		}
+		team.Points = closedPoints + scores[team.ID].Points
+	} else {
+		totalPoints := 0
+		for _, player := range starters {
+			totalPoints += int(math.Round(float64(intValue(player.Points)) * team.pointsMultiplier(player.ID)))
+		}
+		team.Points = totalPoints - team.TransferPenalty
 	}
-
-	team.Points = totalPoints
 	team.Value = totalValue
+	team.Full = len(starters) == SquadSize
 
-	UpdateTeamByID(team)
+	return tx.Model(team).Updates(map[string]interface{}{
+		"points": team.Points,
+		"value":  team.Value,
+		"full":   team.Full,
+	}).Error
 }
 
-func UpdateAllTeamsPointsAndValue() {
-	teams, err := GetAllTeams()
-	if err != nil {
-		fmt.Printf("Error updating all teams points and value: %v\n", err)
-		return
+// refreshTeamPointsAndValue locks the team, reloads its squad with the current player stats and recalculates
+// it from the source loaded for its season
+func refreshTeamPointsAndValue(teamID uint, source *teamPointsSource) error {
+	return db.ORM.Transaction(func(tx *gorm.DB) error {
+		var team Team
+		if err := lockTeam(tx, &team, "id = ?", teamID); err != nil {
+			return err
+		}
+		return saveTeamPointsAndValue(tx, &team, source)
+	})
+}
+
+func newTeamPlayersView(team *Team) *TeamPlayersView {
+	starters, bench := team.splitSquad()
+	view := &TeamPlayersView{
+		ID:            team.ID,
+		TeamName:      team.Name,
+		Players:       convertPlayers(starters),
+		Bench:         convertPlayers(bench),
+		Prices:        []SquadPlayerPrice{},
+		Points:        team.Points,
+		Value:         team.Value,
+		Full:          team.Full,
+		IsFound:       true,
+		CaptainID:     team.CaptainID,
+		ViceCaptainID: team.ViceCaptainID,
 	}
-	for _, team := range teams {
-		team.Full = len(team.Players) == 11
-		updateTeamPointsAndValue(team)
+	for _, player := range team.Players {
+		purchasePrice, sellingPrice := team.squadPrices(player)
+		view.Prices = append(view.Prices, SquadPlayerPrice{PlayerID: player.ID, PurchasePrice: purchasePrice, SellingPrice: sellingPrice})
+		view.SellingValue += sellingPrice
+	}
+	return view
+}
+
+// GetAvailableBudget returns what the team can still spend: the season's budget and the profit or loss on
+// the players sold, less the purchase prices of the current squad. Price changes of squad players do not
+// change it until they are sold. Deleted players are left out, as they are when the squad is changed.
+func GetAvailableBudget(team *Team, budget int) (int, error) {
+	var spent int
+	result := db.ORM.Model(&SquadPlayer{}).
+		Joins("JOIN players ON players.id = team_players.player_id AND players.deleted_at IS NULL").
+		Where("team_players.team_id = ?", team.ID).
+		Select("COALESCE(SUM(team_players.purchase_price), 0)").
+		Scan(&spent)
+	if result.Error != nil {
+		return 0, result.Error
 	}
-	fmt.Printf("Updated all teams points and value\n")
+	return budget + team.SellingProfit - spent, nil
 }
 
-// GetTeamPlayersViewByUserID retrieves a team and its players by user ID and returns it as TeamPlayersView
-func GetMyTeamModel(userID uint) (*TeamPlayersView, error) {
+// GetTeamPlayersViewByUserID retrieves the user's team of the season and its players and returns it as TeamPlayersView
+func GetMyTeamModel(userID uint, seasonID uint) (*TeamPlayersView, error) {
 	var team Team
-	result := db.ORM.Preload("Players").Where("user_id = ?", userID).First(&team)
+	result := db.ORM.Preload("Players").Preload("Squad").Where("user_id = ? AND season_id = ?", userID, seasonID).First(&team)
 	if result.Error != nil {
 		return &TeamPlayersView{
 			IsFound: false,
 		}, nil
 	}
 
-	teamPlayersView := &TeamPlayersView{
-		TeamName: team.Name,
-		Players:  convertPlayers(team.Players),
-		Points:   team.Points,
-		Value:    team.Value,
-		IsFound:  true,
-	}
-
-	return teamPlayersView, nil
+	return newTeamPlayersView(&team), nil
 }
 
 func GetTeamByID(id string) (*Team, error) {
@@ -150,9 +604,10 @@ func GetTeamByID(id string) (*Team, error) {
 	return team, nil
 }
 
-func GetTeamByUserID(userID string) (*Team, error) {
+// GetTeamByUserID retrieves the user's team of the season
+func GetTeamByUserID(userID string, seasonID uint) (*Team, error) {
 	var team *Team
-	result := db.ORM.Where("user_id = ?", userID).First(&team)
+	result := db.ORM.Where("user_id = ? AND season_id = ?", userID, seasonID).First(&team)
 
 	if result.Error != nil {
 		return nil, result.Error
@@ -160,26 +615,24 @@ func GetTeamByUserID(userID string) (*Team, error) {
 	return team, nil
 }
 
+// GetTeamsByPlayerID returns the teams that have the player in their squad
 func GetTeamsByPlayerID(playerID uint) ([]*Team, error) {
 	var teams []*Team
 	result := db.ORM.Model(&Team{}).
-		Joins("JOIN player_teams ON player_teams.team_id = teams.id").
-		Where("player_teams.player_id = ?", playerID).
+		Joins("JOIN team_players ON team_players.team_id = teams.id").
+		Where("team_players.player_id = ?", playerID).
 		Find(&teams)
 
 	if result.Error != nil {
 		return nil, result.Error
 	}
-	for _, team := range teams {
-		fmt.Printf("Team Name: %s, Points: %d, Value: %d\n", team.Name, team.Points, team.Value)
-	}
 	return teams, nil
 }
 
-func GetAllTeams() ([]*Team, error) {
+func GetAllTeams(seasonID uint) ([]*Team, error) {
 	var teams []*Team
 
-	result := db.ORM.Model(&Team{}).Preload("Players").Preload("User").Find(&teams)
+	result := db.ORM.Model(&Team{}).Preload("Players").Preload("Squad").Preload("User").Where("season_id = ?", seasonID).Find(&teams)
 	if result.Error != nil {
 		return nil, result.Error
 	}
@@ -198,22 +651,52 @@ func GetTeamsByFilters(filters map[string]interface{}) ([]*Team, error) {
 	return teams, nil
 }
 
-// UpdateTeamByID updates an existing team record in the database
+// UpdateTeamByID updates an existing team record in the database, new teams are created in the active season.
+// Teams of ended seasons are read-only.
 func UpdateTeamByID(team *Team) error {
+	if team.SeasonID == 0 {
+		seasonID, err := getActiveSeasonID(db.ORM)
+		if err != nil {
+			return err
+		}
+		team.SeasonID = seasonID
+	}
+	if err := checkSeasonWritable(db.ORM, team.SeasonID); err != nil {
+		return err
+	}
 	result := db.ORM.Save(&team)
 	return result.Error
 }
 
-// DeleteTeamByID deletes a team record from the database by ID
+// SaveTeamName creates the team or changes only its name, leaving the squad and the derived totals
+// to the transactions that own them
+func SaveTeamName(team *Team) error {
+	if team.ID == 0 {
+		return UpdateTeamByID(team)
+	}
+	if err := checkSeasonWritable(db.ORM, team.SeasonID); err != nil {
+		return err
+	}
+	result := db.ORM.Model(team).Update("name", team.Name)
+	return result.Error
+}
+
+// DeleteTeamByID deletes a team record from the database by ID, teams of ended seasons are kept
 func DeleteTeamByID(id string) error {
-	var team *Team
-	result := db.ORM.Delete(&team, id)
+	team, err := GetTeamByID(id)
+	if err != nil {
+		return err
+	}
+	if err := checkSeasonWritable(db.ORM, team.SeasonID); err != nil {
+		return err
+	}
+	result := db.ORM.Delete(team)
 	return result.Error
 }
 
-func GetTeamLeaderBoard() ([]*Team, error) {
+func GetTeamLeaderBoard(seasonID uint) ([]*Team, error) {
 	var teams []*Team
-	result := db.ORM.Model(&Team{}).Preload("User").Where(&Team{Full: true}).Order("points desc").Find(&teams)
+	result := db.ORM.Model(&Team{}).Preload("User").Where(&Team{SeasonID: seasonID, Full: true}).Order("points desc").Find(&teams)
 	if result.Error != nil {
 		return nil, result.Error
 	}
@@ -0,0 +1,72 @@
+// models/ticket.model.go
+package models
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"time"
+)
+
+// Time a stream ticket can be redeemed after it was issued
+const StreamTicketTTL = 30 * time.Second
+
+var ErrInvalidTicket = errors.New("invalid or expired ticket")
+
+// StreamTicket lets a browser open the websocket or the event stream without putting its JWT in the
+// URL, where it would end up in access logs. A ticket is short-lived and can be redeemed once.
+type StreamTicket struct {
+	Ticket    string    `json:"ticket"`
+	ExpiresAt time.Time `json:"expires_at"`
+	UserID    uint      `json:"-"`
+	Role      string    `json:"-"`
+}
+
+type streamTicketStore struct {
+	mu      sync.Mutex
+	tickets map[string]*StreamTicket
+}
+
+var streamTickets = &streamTicketStore{tickets: make(map[string]*StreamTicket)}
+
+// IssueStreamTicket returns a new ticket for the user, dropping the expired tickets nobody redeemed
+func IssueStreamTicket(userID uint, role string) (*StreamTicket, error) {
+	random := make([]byte, 32)
+	if _, err := rand.Read(random); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	ticket := &StreamTicket{
+		Ticket:    hex.EncodeToString(random),
+		ExpiresAt: now.Add(StreamTicketTTL),
+		UserID:    userID,
+		Role:      role,
+	}
+
+	streamTickets.mu.Lock()
+	defer streamTickets.mu.Unlock()
+	for key, issued := range streamTickets.tickets {
+		if now.After(issued.ExpiresAt) {
+			delete(streamTickets.tickets, key)
+		}
+	}
+	streamTickets.tickets[ticket.Ticket] = ticket
+	return ticket, nil
+}
+
+// RedeemStreamTicket returns the ticket and removes it, so it cannot be used again
+func RedeemStreamTicket(key string) (*StreamTicket, error) {
+	streamTickets.mu.Lock()
+	defer streamTickets.mu.Unlock()
+
+	ticket, ok := streamTickets.tickets[key]
+	if !ok {
+		return nil, ErrInvalidTicket
+	}
+	delete(streamTickets.tickets, key)
+	if time.Now().After(ticket.ExpiresAt) {
+		return nil, ErrInvalidTicket
+	}
+	return ticket, nil
+}
@@ -0,0 +1,180 @@
+// models/transfer.model.go
+package models
+
+import (
+	"go-orm-template/config"
+	"go-orm-template/db"
+
+	"gorm.io/gorm"
+)
+
+const (
+	TransferIn  = "in"
+	TransferOut = "out"
+)
+
+// Transfer records a player joining or leaving a team. Transfers made after the initial squad
+// was selected count towards the round's free transfers, extra ones cost penalty points.
+type Transfer struct {
+	GormModel
+	TeamID        uint    `json:"team_id" gorm:"not null;index"`
+	PlayerID      uint    `json:"player_id" gorm:"not null;index"`
+	Player        *Player `json:"player,omitempty" gorm:"foreignKey:PlayerID"`
+	Direction     string  `json:"direction"`
+	Round         int     `json:"round" gorm:"index"`
+	Price         int     `json:"price"`
+	Counted       bool    `json:"counted"`
+	PenaltyPoints int     `json:"penalty_points"`
+}
+
+type TransferHistory struct {
+	Round                  int         `json:"round"`
+	FreeTransfers          int         `json:"free_transfers"`
+	UsedTransfers          int         `json:"used_transfers"`
+	RemainingFreeTransfers int         `json:"remaining_free_transfers"`
+	PenaltyPoints          int         `json:"penalty_points"`
+	Transfers              []*Transfer `json:"transfers"`
+}
+
+// countRoundTransfers returns how many counted transfers the team has made in the round
+func countRoundTransfers(tx *gorm.DB, teamID uint, round int) (int, error) {
+	var count int64
+	result := tx.Model(&Transfer{}).
+		Where("team_id = ? AND round = ? AND direction = ? AND counted = ?", teamID, round, TransferIn, true).
+		Count(&count)
+	return int(count), result.Error
+}
+
+// sellingPrice returns what a team gets for a player bought at the purchase price. The team keeps the
+// configured share of a price rise, rounded down, and bears a price fall in full.
+func sellingPrice(purchasePrice int, currentValue int) int {
+	if currentValue <= purchasePrice {
+		return currentValue
+	}
+	return purchasePrice + (currentValue-purchasePrice)*config.SellingPriceRisePercent/100
+}
+
+// squadPrices returns the purchase and selling price of a player in the team's loaded squad, a player
+// that is not in the squad yet is bought and sold at their current value
+func (team *Team) squadPrices(player *Player) (int, int) {
+	for _, row := range team.Squad {
+		if row.PlayerID == player.ID {
+			return row.PurchasePrice, sellingPrice(row.PurchasePrice, intValue(player.Value))
+		}
+	}
+	return intValue(player.Value), intValue(player.Value)
+}
+
+// salesProfit returns the profit or loss made by selling the squad players that are not in the new squad
+func (team *Team) salesProfit(newPlayers []*Player) int {
+	kept := make(map[uint]bool)
+	for _, player := range newPlayers {
+		kept[player.ID] = true
+	}
+
+	profit := 0
+	for _, player := range team.Players {
+		if !kept[player.ID] {
+			purchasePrice, sellingPrice := team.squadPrices(player)
+			profit += sellingPrice - purchasePrice
+		}
+	}
+	return profit
+}
+
+// recordTransfers stores the transfers between the old and new squad and returns the penalty points they cost
+func recordTransfers(tx *gorm.DB, team *Team, oldPlayers []*Player, newPlayers []*Player) (int, error) {
+	round, err := getLastSnapshotRound(tx, team.SeasonID)
+	if err != nil {
+		return 0, err
+	}
+	round++
+
+	oldIDs := make(map[uint]bool)
+	for _, player := range oldPlayers {
+		oldIDs[player.ID] = true
+	}
+	newIDs := make(map[uint]bool)
+	for _, player := range newPlayers {
+		newIDs[player.ID] = true
+	}
+
+	counted := team.InitialSquadSelected
+	used, err := countRoundTransfers(tx, team.ID, round)
+	if err != nil {
+		return 0, err
+	}
+
+	var transfers []*Transfer
+	for _, player := range oldPlayers {
+		if !newIDs[player.ID] {
+			_, price := team.squadPrices(player)
+			transfers = append(transfers, &Transfer{
+				TeamID:    team.ID,
+				PlayerID:  player.ID,
+				Direction: TransferOut,
+				Round:     round,
+				Price:     price,
+				Counted:   counted,
+			})
+		}
+	}
+
+	penalty := 0
+	for _, player := range newPlayers {
+		if oldIDs[player.ID] {
+			continue
+		}
+		transfer := &Transfer{
+			TeamID:    team.ID,
+			PlayerID:  player.ID,
+			Direction: TransferIn,
+			Round:     round,
+			Price:     intValue(player.Value),
+			Counted:   counted,
+		}
+		if counted {
+			used++
+			if used > config.FreeTransfersPerRound {
+				transfer.PenaltyPoints = config.TransferPenaltyPoints
+				penalty += transfer.PenaltyPoints
+			}
+		}
+		transfers = append(transfers, transfer)
+	}
+
+	if len(transfers) > 0 {
+		if err := tx.Create(&transfers).Error; err != nil {
+			return 0, err
+		}
+	}
+	return penalty, nil
+}
+
+// GetTransferHistory returns the transfers of a team with the free transfers left in the season's current round
+func GetTransferHistory(team *Team) (*TransferHistory, error) {
+	round, err := GetCurrentRound(team.SeasonID)
+	if err != nil {
+		return nil, err
+	}
+
+	history := &TransferHistory{
+		Round:         round,
+		FreeTransfers: config.FreeTransfersPerRound,
+		Transfers:     []*Transfer{},
+	}
+
+	result := db.ORM.Preload("Player").Where("team_id = ?", team.ID).Order("created_at desc, id desc").Find(&history.Transfers)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	for _, transfer := range history.Transfers {
+		history.PenaltyPoints += transfer.PenaltyPoints
+		if transfer.Round == round && transfer.Direction == TransferIn && transfer.Counted {
+			history.UsedTransfers++
+		}
+	}
+	history.RemainingFreeTransfers = max(0, history.FreeTransfers-history.UsedTransfers)
+	return history, nil
+}
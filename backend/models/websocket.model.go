@@ -1,7 +1,15 @@
 package models
 
 import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go-orm-template/config"
 	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/gorilla/websocket"
 )
@@ -9,7 +17,425 @@ import (
 var Upgrader = websocket.Upgrader{
 	ReadBufferSize:  1024,
 	WriteBufferSize: 1024,
+	// Browsers may only connect from the configured origins. Other clients send no origin and
+	// still need a valid token.
 	CheckOrigin: func(r *http.Request) bool {
-		return true
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			return true
+		}
+		for _, allowed := range config.SocketAllowedOrigins {
+			if origin == allowed {
+				return true
+			}
+		}
+		return false
 	},
 }
+
+const (
+	TopicPlayer      = "player"
+	TopicTeam        = "team"
+	TopicSeason      = "season"
+	TopicLeaderboard = "leaderboard"
+	TopicSummary     = "summary"
+
+	SocketAuthWait   = 10 * time.Second // Time a client has to send its token after connecting
+	socketWriteWait  = 10 * time.Second
+	socketPongWait   = 60 * time.Second
+	socketPingPeriod = socketPongWait * 9 / 10
+	socketSendBuffer = 64 // Messages queued for a client before it is considered dead
+	socketMaxMessage = 4096
+)
+
+// WebSocketHub tracks the connected clients and delivers published events to those subscribed to
+// any of the event's topics. A client that has not subscribed to anything receives every event.
+type WebSocketHub struct {
+	mu      sync.RWMutex
+	clients map[*WebSocketClient]bool
+	events  *eventLog
+}
+
+// WebSocketClient is an authenticated connection to the hub. Messages are queued and written by a single
+// goroutine, so writes to the connection never overlap. Event streams are clients without a connection
+// that read their queue themselves.
+type WebSocketClient struct {
+	hub    *WebSocketHub
+	conn   *websocket.Conn
+	send   chan HubMessage
+	mu     sync.Mutex
+	topics map[string]bool
+	closed bool
+	joined uint64 // Last event published before the client connected
+	UserID uint
+	Role   string
+}
+
+// HubMessage is a queued message for a client, Seq is set for change events only
+type HubMessage struct {
+	Seq  uint64
+	Data []byte
+}
+
+// SocketMessage is a request from a client to authenticate, change its subscriptions or catch up on
+// the events after Seq
+type SocketMessage struct {
+	Action string   `json:"action"` // auth, subscribe, unsubscribe or resume
+	Token  string   `json:"token,omitempty"`
+	Topics []string `json:"topics,omitempty"`
+	Seq    uint64   `json:"seq,omitempty"`
+}
+
+var SocketHub = &WebSocketHub{clients: make(map[*WebSocketClient]bool), events: newEventLog()}
+
+// ValidateTopic checks that the topic is a known topic, optionally narrowed to a player or team ID
+// as in player:<id> or team:<id>
+func ValidateTopic(topic string) error {
+	name, id, scoped := strings.Cut(topic, ":")
+	switch name {
+	case TopicPlayer, TopicTeam, TopicSeason:
+		if !scoped {
+			return nil
+		}
+		if _, err := strconv.ParseUint(id, 10, 64); err != nil {
+			return fmt.Errorf("invalid ID in topic %s", topic)
+		}
+		return nil
+	case TopicLeaderboard, TopicSummary:
+		if !scoped {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown topic %s", topic)
+}
+
+func (h *WebSocketHub) newClient(conn *websocket.Conn, userID uint, role string) *WebSocketClient {
+	return &WebSocketClient{
+		hub:    h,
+		conn:   conn,
+		send:   make(chan HubMessage, socketSendBuffer),
+		topics: make(map[string]bool),
+		UserID: userID,
+		Role:   role,
+	}
+}
+
+// Register adds the authenticated connection to the hub and starts writing its messages
+func (h *WebSocketHub) Register(conn *websocket.Conn, userID uint, role string) *WebSocketClient {
+	client := h.newClient(conn, userID, role)
+
+	h.mu.Lock()
+	client.joined = h.events.seq
+	h.clients[client] = true
+	h.mu.Unlock()
+
+	go client.writeMessages()
+	return client
+}
+
+// Listen adds an event stream for the user to the hub, subscribed to the topics. With lastSeq it also
+// returns the events of its topics published after lastSeq, or false when they are no longer kept and
+// the stream has to start over. The caller reads Messages until it is closed and calls Close when done.
+func (h *WebSocketHub) Listen(userID uint, role string, topics []string, lastSeq *uint64) (*WebSocketClient, []HubMessage, bool, error) {
+	client := h.newClient(nil, userID, role)
+	if err := client.Subscribe(topics); err != nil {
+		return nil, nil, false, err
+	}
+
+	// The missed events and the stream's registration are taken under one lock, so no event is
+	// sent twice or lost in between
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	client.joined = h.events.seq
+	h.clients[client] = true
+
+	if lastSeq == nil {
+		return client, nil, true, nil
+	}
+	events, ok := h.events.since(*lastSeq)
+	return client, client.missedEvents(events), ok, nil
+}
+
+// unregister removes the client from the hub and stops its writer, which closes the connection
+func (h *WebSocketHub) unregister(client *WebSocketClient) {
+	h.mu.Lock()
+	delete(h.clients, client)
+	h.mu.Unlock()
+
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	if !client.closed {
+		client.closed = true
+		close(client.send)
+	}
+}
+
+// Publish numbers the event, logs it and sends it to every client subscribed to any of the topics.
+// Admins receive the admin event and everyone else the user event, which must leave out admin-only
+// fields. A topic such as player:* reaches the subscribers of every player, for changes to many players
+// at once. Clients whose queue is full are not keeping up and are evicted.
+func (h *WebSocketHub) Publish(topics []string, adminEvent *ChangeEvent, userEvent *ChangeEvent) error {
+	// Numbering, logging and sending under one lock keeps the events of every client in order. The
+	// number is only used up when the event is logged, after both versions marshalled.
+	h.mu.Lock()
+	seq := h.events.next()
+	adminEvent.Seq = seq
+	userEvent.Seq = seq
+	adminData, err := json.Marshal(adminEvent)
+	if err != nil {
+		h.mu.Unlock()
+		return err
+	}
+	userData, err := json.Marshal(userEvent)
+	if err != nil {
+		h.mu.Unlock()
+		return err
+	}
+	h.events.add(&loggedEvent{seq: seq, topics: topics, adminData: adminData, userData: userData})
+
+	var evicted []*WebSocketClient
+	for client := range h.clients {
+		if !client.subscribed(topics) {
+			continue
+		}
+		data := userData
+		if client.Role == "admin" {
+			data = adminData
+		}
+		select {
+		case client.send <- HubMessage{Seq: seq, Data: data}:
+		default:
+			evicted = append(evicted, client)
+		}
+	}
+	h.mu.Unlock()
+
+	for _, client := range evicted {
+		h.unregister(client)
+	}
+	return nil
+}
+
+// ClientCount returns the number of connected clients
+func (h *WebSocketHub) ClientCount() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.clients)
+}
+
+func (c *WebSocketClient) subscribed(topics []string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return false
+	}
+	if len(c.topics) == 0 {
+		return true
+	}
+	for _, topic := range topics {
+		if c.topics[topic] {
+			return true
+		}
+		if prefix, ok := strings.CutSuffix(topic, "*"); ok {
+			for subscribed := range c.topics {
+				if strings.HasPrefix(subscribed, prefix) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// Resume sends the client the events of its topics published after seq and before it connected, in one
+// message with the events in order:
+//
+//	{"action": "resumed", "seq": <seq>, "events": [...]}
+//
+// Events published since it connected have already been sent. When the events after seq are no longer
+// kept, the client gets {"action": "resync"} and has to refetch everything it shows.
+func (c *WebSocketClient) Resume(seq uint64) error {
+	c.hub.mu.RLock()
+	events, ok := c.hub.events.since(seq)
+	missed := c.missedEvents(events)
+	c.hub.mu.RUnlock()
+
+	var reply interface{} = map[string]string{"action": "resync"}
+	if ok {
+		data := make([][]byte, len(missed))
+		for i, message := range missed {
+			data[i] = message.Data
+		}
+		reply = map[string]interface{}{
+			"action": "resumed",
+			"seq":    seq,
+			"events": json.RawMessage(append(append([]byte("["), bytes.Join(data, []byte(","))...), ']')),
+		}
+	}
+	data, err := json.Marshal(reply)
+	if err != nil {
+		return err
+	}
+
+	// A client that is not keeping up would think it caught up when the reply is dropped, so it is
+	// evicted instead and resumes again after reconnecting
+	if !c.queue(HubMessage{Data: data}) {
+		c.hub.unregister(c)
+	}
+	return nil
+}
+
+// missedEvents returns the logged events of the client's topics published before it joined, as sent to
+// its role. The caller must hold the hub's lock.
+func (c *WebSocketClient) missedEvents(events []*loggedEvent) []HubMessage {
+	var missed []HubMessage
+	for _, event := range events {
+		if event.seq > c.joined {
+			break
+		}
+		if !c.subscribed(event.topics) {
+			continue
+		}
+		if c.Role == "admin" {
+			missed = append(missed, HubMessage{Seq: event.seq, Data: event.adminData})
+		} else {
+			missed = append(missed, HubMessage{Seq: event.seq, Data: event.userData})
+		}
+	}
+	return missed
+}
+
+// Joined returns the sequence number of the last event published before the client connected
+func (c *WebSocketClient) Joined() uint64 {
+	return c.joined
+}
+
+// Messages returns the queue of an event stream, it is closed when the stream is removed from the hub
+func (c *WebSocketClient) Messages() <-chan HubMessage {
+	return c.send
+}
+
+// Close removes the client from the hub
+func (c *WebSocketClient) Close() {
+	c.hub.unregister(c)
+}
+
+// Topics returns the topics the client is subscribed to
+func (c *WebSocketClient) Topics() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	topics := []string{}
+	for topic := range c.topics {
+		topics = append(topics, topic)
+	}
+	return topics
+}
+
+// Subscribe adds the topics to the client's subscriptions
+func (c *WebSocketClient) Subscribe(topics []string) error {
+	for _, topic := range topics {
+		if err := ValidateTopic(topic); err != nil {
+			return err
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, topic := range topics {
+		c.topics[topic] = true
+	}
+	return nil
+}
+
+// Unsubscribe removes the topics from the client's subscriptions
+func (c *WebSocketClient) Unsubscribe(topics []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, topic := range topics {
+		delete(c.topics, topic)
+	}
+}
+
+// Send queues a message for the client only, it is dropped when the client is gone or not keeping up
+func (c *WebSocketClient) Send(message interface{}) error {
+	data, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+
+	c.queue(HubMessage{Data: data})
+	return nil
+}
+
+// queue adds the message to the client's queue, returning false when the client is gone or its queue is full
+func (c *WebSocketClient) queue(message HubMessage) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return false
+	}
+	select {
+	case c.send <- message:
+		return true
+	default:
+		return false
+	}
+}
+
+// ReadMessages reads the client's subscription requests until the connection fails, calling handle for
+// each one, then removes the client from the hub. Missed pongs end the connection.
+func (c *WebSocketClient) ReadMessages(handle func(message SocketMessage)) {
+	defer c.hub.unregister(c)
+
+	c.conn.SetReadLimit(socketMaxMessage)
+	c.conn.SetReadDeadline(time.Now().Add(socketPongWait))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(socketPongWait))
+	})
+
+	for {
+		_, data, err := c.conn.ReadMessage()
+		if err != nil {
+			return
+		}
+
+		var message SocketMessage
+		if err := json.Unmarshal(data, &message); err != nil {
+			c.Send(map[string]string{"error": "invalid message: " + err.Error()})
+			continue
+		}
+		handle(message)
+	}
+}
+
+// writeMessages writes the queued messages and pings the client, closing the connection when a write
+// fails or the client is unregistered
+func (c *WebSocketClient) writeMessages() {
+	ticker := time.NewTicker(socketPingPeriod)
+	defer func() {
+		ticker.Stop()
+		c.conn.Close()
+		c.hub.unregister(c)
+	}()
+
+	for {
+		select {
+		case message, ok := <-c.send:
+			c.conn.SetWriteDeadline(time.Now().Add(socketWriteWait))
+			if !ok {
+				c.conn.WriteMessage(websocket.CloseMessage, []byte{})
+				return
+			}
+			if err := c.conn.WriteMessage(websocket.TextMessage, message.Data); err != nil {
+				return
+			}
+		case <-ticker.C:
+			c.conn.SetWriteDeadline(time.Now().Add(socketWriteWait))
+			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
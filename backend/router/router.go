@@ -39,9 +39,47 @@ var routes = []Route{
 	{Path: "/players/:id", Security: "Admin", Method: "PUT", Handler: handlers.UpdatePlayer},
 	{Path: "/players/:id", Security: "Admin", Method: "DELETE", Handler: handlers.DeletePlayer},
 	{Path: "/players/filter", Security: "Admin", Method: "GET", Handler: handlers.GetAllPlayersByFilter},
+	{Path: "/players/:id/deliveries", Security: "Admin", Method: "GET", Handler: handlers.GetPlayerDeliveries},
+	{Path: "/players/:id/history", Security: "Admin", Method: "GET", Handler: handlers.GetPlayerHistory},
+	{Path: "/players/market/run", Security: "Admin", Method: "POST", Handler: handlers.RunMarket},
+	{Path: "/players/market/runs", Security: "Admin", Method: "GET", Handler: handlers.GetMarketRuns},
 
 	{Path: "/v1/players/filter", Security: "User", Method: "GET", Handler: handlers.GetAllPlayersByFilter},
 	{Path: "/v1/players/:id", Security: "User", Method: "GET", Handler: handlers.GetPlayerByIDForUser},
+	{Path: "/v1/players/:id/history", Security: "User", Method: "GET", Handler: handlers.GetPlayerHistory},
+
+	//match routes
+	{Path: "/matches/add", Security: "Admin", Method: "POST", Handler: handlers.AddMatch},
+	{Path: "/matches", Security: "Admin", Method: "GET", Handler: handlers.GetAllMatches},
+	{Path: "/matches/:id", Security: "Admin", Method: "GET", Handler: handlers.GetMatchByID},
+	{Path: "/matches/:id", Security: "Admin", Method: "PUT", Handler: handlers.UpdateMatch},
+	{Path: "/matches/:id/stats", Security: "Admin", Method: "GET", Handler: handlers.GetMatchStats},
+	{Path: "/matches/:id/innings", Security: "Admin", Method: "POST", Handler: handlers.AddInnings},
+	{Path: "/innings/:id/deliveries", Security: "Admin", Method: "POST", Handler: handlers.RecordDelivery},
+	{Path: "/innings/:id/deliveries/last", Security: "Admin", Method: "DELETE", Handler: handlers.UndoLastDelivery},
+
+	{Path: "/v1/fixtures", Security: "User", Method: "GET", Handler: handlers.GetFixtures},
+	{Path: "/v1/fixtures/deadline", Security: "User", Method: "GET", Handler: handlers.GetRoundDeadline},
+
+	//scoring rule routes
+	{Path: "/scoring/rules/add", Security: "Admin", Method: "POST", Handler: handlers.AddScoringRuleSet},
+	{Path: "/scoring/rules", Security: "Admin", Method: "GET", Handler: handlers.GetAllScoringRuleSets},
+	{Path: "/scoring/rules/active", Security: "Admin", Method: "GET", Handler: handlers.GetActiveScoringRuleSet},
+	{Path: "/scoring/rules/:id", Security: "Admin", Method: "GET", Handler: handlers.GetScoringRuleSetByID},
+	{Path: "/scoring/rules/:id", Security: "Admin", Method: "PUT", Handler: handlers.UpdateScoringRuleSet},
+	{Path: "/scoring/rules/:id", Security: "Admin", Method: "DELETE", Handler: handlers.DeleteScoringRuleSet},
+	{Path: "/scoring/rules/:id/preview", Security: "Admin", Method: "GET", Handler: handlers.PreviewScoringRuleSet},
+	{Path: "/scoring/rules/:id/activate", Security: "Admin", Method: "POST", Handler: handlers.ActivateScoringRuleSet},
+
+	//season routes
+	{Path: "/seasons/add", Security: "Admin", Method: "POST", Handler: handlers.AddSeason},
+	{Path: "/seasons", Security: "Admin", Method: "GET", Handler: handlers.GetAllSeasons},
+	{Path: "/seasons/:id", Security: "Admin", Method: "GET", Handler: handlers.GetSeasonByID},
+	{Path: "/seasons/:id", Security: "Admin", Method: "PUT", Handler: handlers.UpdateSeason},
+	{Path: "/seasons/:id/activate", Security: "Admin", Method: "POST", Handler: handlers.ActivateSeason},
+
+	{Path: "/v1/seasons", Security: "User", Method: "GET", Handler: handlers.GetAllSeasons},
+	{Path: "/v1/seasons/active", Security: "User", Method: "GET", Handler: handlers.GetActiveSeason},
 
 	//Touranment routes
 	{Path: "/tournament/summary", Security: "Admin", Method: "GET", Handler: handlers.GetTournamentSummary},
@@ -53,12 +91,37 @@ var routes = []Route{
 	{Path: "/teams/:id", Security: "Admin", Method: "GET", Handler: handlers.GetTeamByID},
 	{Path: "/teams/:id", Security: "Admin", Method: "PUT", Handler: handlers.UpdateTeam},
 	{Path: "/teams/:id", Security: "Admin", Method: "DELETE", Handler: handlers.DeleteTeam},
+	{Path: "/teams/:id/history", Security: "Admin", Method: "GET", Handler: handlers.GetTeamHistory},
+	{Path: "/teams/:id/transfers", Security: "Admin", Method: "GET", Handler: handlers.GetTeamTransfers},
+	{Path: "/teams/:id/players/assign", Security: "Admin", Method: "POST", Handler: handlers.AssignPlayersToTeamByID},
+	{Path: "/teams/leaderboard/snapshot", Security: "Admin", Method: "POST", Handler: handlers.SnapshotRound},
+	{Path: "/teams/recompute", Security: "Admin", Method: "POST", Handler: handlers.RecomputeTeams},
+	{Path: "/teams/recompute/status", Security: "Admin", Method: "GET", Handler: handlers.GetTeamRecomputeStatus},
 
 	{Path: "/v1/teams/players/assign", Security: "User", Method: "POST", Handler: handlers.AssingPlayersToTeamByUserID},
+	{Path: "/v1/teams/rules", Security: "User", Method: "GET", Handler: handlers.GetSquadRules},
 	{Path: "/v1/teams/my", Security: "User", Method: "GET", Handler: handlers.GetMyTeam},
 	{Path: "/v1/teams/my", Security: "User", Method: "PUT", Handler: handlers.UpdateMyTeam},
+	{Path: "/v1/teams/my/captain", Security: "User", Method: "POST", Handler: handlers.SetMyTeamCaptains},
+	{Path: "/v1/teams/my/history", Security: "User", Method: "GET", Handler: handlers.GetMyTeamHistory},
+	{Path: "/v1/teams/my/transfers", Security: "User", Method: "GET", Handler: handlers.GetMyTransfers},
 	{Path: "/v1/teams/leaderboard", Security: "User", Method: "GET", Handler: handlers.GetTeamLeaderBoard},
 
+	//league routes
+	{Path: "/leagues", Security: "Admin", Method: "GET", Handler: handlers.GetAllLeagues},
+
+	{Path: "/v1/leagues/add", Security: "User", Method: "POST", Handler: handlers.AddLeague},
+	{Path: "/v1/leagues/join", Security: "User", Method: "POST", Handler: handlers.JoinLeague},
+	{Path: "/v1/leagues/my", Security: "User", Method: "GET", Handler: handlers.GetMyLeagues},
+	{Path: "/v1/leagues/:id", Security: "User", Method: "GET", Handler: handlers.GetLeague},
+	{Path: "/v1/leagues/:id/leaderboard", Security: "User", Method: "GET", Handler: handlers.GetLeagueLeaderBoard},
+	{Path: "/v1/leagues/:id/fixtures", Security: "User", Method: "GET", Handler: handlers.GetLeagueFixtures},
+	{Path: "/v1/leagues/:id/standings", Security: "User", Method: "GET", Handler: handlers.GetLeagueStandings},
+	{Path: "/v1/leagues/:id/members/:user_id", Security: "User", Method: "DELETE", Handler: handlers.RemoveLeagueMember},
+
+	//live update routes
+	{Path: "/v1/events/ticket", Security: "User", Method: "POST", Handler: handlers.IssueStreamTicket},
+
 	//AI Chat routes
 	{Path: "/v1/ai/chat", Security: "User", Method: "POST", Handler: handlers.GetResponse},
 }
@@ -100,8 +163,10 @@ func NewRouter() *gin.Engine {
 		}
 	}
 
-	// Register WebSocket route
+	// Register the WebSocket and event stream routes, they check the ticket or token themselves as
+	// browsers cannot send headers with the upgrade or an EventSource
 	r.GET("/socket/players", handlers.PlayerWebSocket)
+	r.GET("/v1/events", handlers.StreamEvents)
 
 	return r
 }
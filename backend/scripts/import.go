@@ -1,87 +1,273 @@
 package scripts
 
 import (
-	"encoding/csv"
 	"fmt"
 	"go-orm-template/auth"
 	"go-orm-template/db"
 	"go-orm-template/models"
-	"os"
-	"strconv"
+	"math"
+	"strings"
 )
 
-func ImportPlayersFromCSV() {
-	// Drop and recreate the table
-	fmt.Println("Dropping and recreating the Player table")
-	db.ORM.Migrator().DropTable(&models.Player{})
-	db.ORM.AutoMigrate(&models.Player{})
+// PlayerImportOptions controls how ImportPlayersFromCSV applies the players file
+type PlayerImportOptions struct {
+	Path       string
+	ReportPath string // Where to write the JSON report, printed when empty
+	DryRun     bool   // Print the diff without touching the database
+	Prune      bool   // Delete players that are no longer in the file
+	Reset      bool   // Delete the active season's players before importing
+	Force      bool   // Import the accepted rows even when some rows are rejected
+}
+
+type playerRow struct {
+	result RowResult
+	player *models.Player
+}
+
+// ImportPlayersFromCSV matches the players in the file against the existing players by name and
+// university, inserting new players and updating changed stats so team selections are kept.
+// Every row is validated first and nothing is imported when a row is rejected, unless forced.
+func ImportPlayersFromCSV(options PlayerImportOptions) error {
+	report := newImportReport(options.Path, options.DryRun)
 
-	file, err := os.Open("data/players.csv")
+	rows, err := readCSV(options.Path, 9, report)
 	if err != nil {
-		fmt.Printf("Error opening file: %v\n", err)
-		return
+		return err
 	}
-	defer file.Close()
 
-	// Create a CSV reader
-	reader := csv.NewReader(file)
+	seen := make(map[string]int)
+	var valid []*playerRow
+	for _, row := range rows {
+		parsed := parsePlayerRow(row)
+		if parsed.player.Name != "" && parsed.player.University != "" {
+			key := playerKey(parsed.player.Name, parsed.player.University)
+			if line, ok := seen[key]; ok {
+				parsed.result.Errors = append(parsed.result.Errors, fmt.Sprintf("duplicate of line %d", line))
+			} else {
+				seen[key] = row.line
+			}
+		}
+		if len(parsed.result.Errors) > 0 {
+			report.add(parsed.result)
+			continue
+		}
+		valid = append(valid, parsed)
+	}
 
-	// Skip header row
-	_, err = reader.Read()
-	if err != nil {
-		fmt.Printf("Error reading header: %v\n", err)
-		return
+	if len(report.Rejected) > 0 && !options.Force {
+		if err := report.write(options.ReportPath); err != nil {
+			return err
+		}
+		return report.rejectedError()
 	}
 
-	// Read and process each row
-	// Skip first record
-	_, err = reader.Read()
-	if err != nil {
-		fmt.Printf("Error skipping first record: %v\n", err)
-		return
+	if !options.DryRun {
+		db.ORM.AutoMigrate(&models.Player{})
+	}
+
+	// Players are imported into the active season, the players of earlier seasons are left untouched
+	var seasonID uint
+	season, err := models.GetActiveSeason()
+	if err != nil && !options.DryRun {
+		return fmt.Errorf("error loading the active season: %w", err)
+	}
+	if season != nil {
+		seasonID = season.ID
 	}
 
-	for {
-		record, err := reader.Read()
+	if options.Reset && !options.DryRun {
+		fmt.Printf("Deleting the players of %s\n", season.Name)
+		if err := models.DeletePlayersBySeasonID(seasonID); err != nil {
+			return fmt.Errorf("error deleting players: %w", err)
+		}
+	}
+
+	existing := make(map[string]*models.Player)
+	if !options.Reset {
+		players, err := models.GetAllPlayers(seasonID)
 		if err != nil {
-			break // Break at EOF
+			return fmt.Errorf("error loading existing players: %w", err)
 		}
+		for _, player := range players {
+			existing[playerKey(player.Name, player.University)] = player
+		}
+	}
 
-		// Convert string values to appropriate types
-		totalRuns, _ := strconv.Atoi(record[3])
-		ballsFaced, _ := strconv.Atoi(record[4])
-		inningsPlayed, _ := strconv.Atoi(record[5])
-		wickets, _ := strconv.Atoi(record[6])
-		oversBowled, _ := strconv.ParseFloat(record[7], 64)
-		runsConceded, _ := strconv.Atoi(record[8])
+	var added, updated []*playerRow
+	unchanged := 0
 
-		// Create player object
-		player := &models.Player{
-			Name:          record[0],
-			University:    record[1],
-			Category:      record[2],
-			TotalRuns:     totalRuns,
-			BallsFaced:    ballsFaced,
-			InningsPlayed: inningsPlayed,
-			Wickets:       wickets,
-			OversBowled:   oversBowled,
-			RunsConceded:  runsConceded,
+	for _, row := range valid {
+		player := row.player
+		current, ok := existing[playerKey(player.Name, player.University)]
+		if !ok {
+			fmt.Printf("+ %s (%s)\n", player.Name, player.University)
+			added = append(added, row)
+			continue
 		}
 
-		fmt.Printf("Adding Player: %s\n", player.Name)
-		// Save to database
+		changes := diffPlayerStats(current, player)
+		if len(changes) == 0 {
+			unchanged++
+			report.add(row.result)
+			continue
+		}
+
+		fmt.Printf("~ %s (%s): %s\n", current.Name, current.University, strings.Join(changes, ", "))
+		current.Category = player.Category
+		current.TotalRuns = player.TotalRuns
+		current.BallsFaced = player.BallsFaced
+		current.InningsPlayed = player.InningsPlayed
+		current.Wickets = player.Wickets
+		current.OversBowled = player.OversBowled
+		current.RunsConceded = player.RunsConceded
+		row.player = current
+		updated = append(updated, row)
+	}
+
+	var removed []*models.Player
+	for key, player := range existing {
+		if _, ok := seen[key]; !ok {
+			fmt.Printf("- %s (%s)\n", player.Name, player.University)
+			removed = append(removed, player)
+		}
+	}
 
-		err = models.AddPlayer(player)
+	fmt.Printf("%d to add, %d to update, %d unchanged, %d not in file\n", len(added), len(updated), unchanged, len(removed))
 
+	if options.DryRun {
+		for _, row := range append(added, updated...) {
+			report.add(row.result)
+		}
+		fmt.Println("Dry run, no changes were made")
+		return report.write(options.ReportPath)
+	}
+
+	for _, row := range added {
+		err = models.AddPlayer(row.player)
 		if err != nil {
-			fmt.Printf("Error creating player %s: %v\n", player.Name, err)
-			continue
+			row.result.Errors = append(row.result.Errors, fmt.Sprintf("error creating player: %v", err))
+		}
+		report.add(row.result)
+	}
+
+	var players []*models.Player
+	for _, row := range updated {
+		players = append(players, row.player)
+	}
+	err = models.SavePlayers(players)
+	for _, row := range updated {
+		if err != nil {
+			row.result.Errors = append(row.result.Errors, fmt.Sprintf("error updating player: %v", err))
+		}
+		report.add(row.result)
+	}
+
+	if options.Prune && len(removed) > 0 {
+		var ids []uint
+		for _, player := range removed {
+			ids = append(ids, player.ID)
+		}
+		err = models.DeletePlayersByIDs(ids)
+		if err != nil {
+			return fmt.Errorf("error deleting players: %w", err)
 		}
+		fmt.Printf("Successfully deleted %d players\n", len(removed))
+	} else if len(removed) > 0 {
+		fmt.Println("Players not in the file were kept, use --prune to delete them")
+	}
 
-		fmt.Printf("Successfully imported player: %s\n", player.Name)
+	// Only teams holding an updated or deleted player change, new players are in no team yet. A reset
+	// deleted every player of the season, so every team changes.
+	var changed []uint
+	for _, player := range players {
+		changed = append(changed, player.ID)
+	}
+	if options.Prune {
+		for _, player := range removed {
+			changed = append(changed, player.ID)
+		}
+	}
+	if options.Reset {
+		err = recomputeAllTeams()
+	} else {
+		err = recomputeTeams(changed)
+	}
+	if err != nil {
+		fmt.Printf("Error updating teams points and value: %v\n", err)
 	}
 
 	fmt.Println("Import completed")
+	if err := report.write(options.ReportPath); err != nil {
+		return err
+	}
+	if len(report.Rejected) > 0 && !options.Force {
+		return report.rejectedError()
+	}
+	return nil
+}
+
+// parsePlayerRow validates a row of the players file and converts it to a player
+func parsePlayerRow(row csvRow) *playerRow {
+	record := row.record
+	v := &rowValidator{result: RowResult{Line: row.line, Name: record[0]}}
+
+	player := &models.Player{
+		Name:          v.required("name", record[0]),
+		University:    v.required("university", record[1]),
+		Category:      record[2],
+		TotalRuns:     v.count("total runs", record[3]),
+		BallsFaced:    v.count("balls faced", record[4]),
+		InningsPlayed: v.count("innings played", record[5]),
+		Wickets:       v.count("wickets", record[6]),
+		OversBowled:   v.decimal("overs bowled", record[7]),
+		RunsConceded:  v.count("runs conceded", record[8]),
+	}
+
+	if !playerCategories[player.Category] {
+		v.errorf("unknown category %q, expected Batsman, Bowler or All-Rounder", player.Category)
+	}
+
+	v.battingSanity(player.TotalRuns, player.BallsFaced)
+	if player.InningsPlayed == 0 && player.BallsFaced > 0 {
+		v.warnf("%d balls faced without playing an innings", player.BallsFaced)
+	}
+	if player.InningsPlayed > 0 && player.BallsFaced == 0 {
+		v.warnf("%d innings played without facing a ball", player.InningsPlayed)
+	}
+	v.bowlingSanity(player.Wickets, int(math.Round(player.OversBowled*6)), player.RunsConceded)
+
+	return &playerRow{result: v.result, player: player}
+}
+
+func playerKey(name string, university string) string {
+	return strings.ToLower(strings.TrimSpace(name)) + "|" + strings.ToLower(strings.TrimSpace(university))
+}
+
+// diffPlayerStats lists the imported fields that differ between the current and imported player
+func diffPlayerStats(current *models.Player, imported *models.Player) []string {
+	var changes []string
+	if current.Category != imported.Category {
+		changes = append(changes, fmt.Sprintf("category %s -> %s", current.Category, imported.Category))
+	}
+	if current.TotalRuns != imported.TotalRuns {
+		changes = append(changes, fmt.Sprintf("total_runs %d -> %d", current.TotalRuns, imported.TotalRuns))
+	}
+	if current.BallsFaced != imported.BallsFaced {
+		changes = append(changes, fmt.Sprintf("balls_faced %d -> %d", current.BallsFaced, imported.BallsFaced))
+	}
+	if current.InningsPlayed != imported.InningsPlayed {
+		changes = append(changes, fmt.Sprintf("innings_played %d -> %d", current.InningsPlayed, imported.InningsPlayed))
+	}
+	if current.Wickets != imported.Wickets {
+		changes = append(changes, fmt.Sprintf("wickets %d -> %d", current.Wickets, imported.Wickets))
+	}
+	if math.Abs(current.OversBowled-imported.OversBowled) > 1e-9 {
+		changes = append(changes, fmt.Sprintf("overs_bowled %g -> %g", current.OversBowled, imported.OversBowled))
+	}
+	if current.RunsConceded != imported.RunsConceded {
+		changes = append(changes, fmt.Sprintf("runs_conceded %d -> %d", current.RunsConceded, imported.RunsConceded))
+	}
+	return changes
 }
 
 func ImportDefaultUsers() {
@@ -137,3 +323,21 @@ func ImportDefaultUsers() {
 		fmt.Printf("Successfully imported user: %s\n", user.Username)
 	}
 }
+
+// recomputeTeams recalculates the teams containing the changed players, printing the progress
+func recomputeTeams(playerIDs []uint) error {
+	fmt.Println("Updating teams points and value")
+	return models.RecomputeTeamsForPlayers(playerIDs, printRecomputeProgress)
+}
+
+// recomputeAllTeams recalculates every team of the active season, printing the progress
+func recomputeAllTeams() error {
+	fmt.Println("Updating teams points and value")
+	return models.RecomputeAllTeams(printRecomputeProgress)
+}
+
+func printRecomputeProgress(done int, total int) {
+	if done == total || done%50 == 0 {
+		fmt.Printf("Updated %d/%d teams\n", done, total)
+	}
+}
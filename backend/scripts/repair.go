@@ -0,0 +1,48 @@
+package scripts
+
+import (
+	"encoding/json"
+	"fmt"
+	"go-orm-template/models"
+	"os"
+)
+
+// RepairOptions controls what RepairIntegrity does with the issues it finds
+type RepairOptions struct {
+	ReportPath string // Where to write the JSON report, printed when empty
+	Fix        bool   // Repair the issues that can be repaired instead of only reporting them
+}
+
+// RepairIntegrity checks the references between users, teams and players and optionally repairs them.
+// The report is written even when recalculating the repaired teams fails, the error is returned after it.
+func RepairIntegrity(options RepairOptions) error {
+	report, recomputeErr := models.CheckIntegrity(options.Fix)
+	if report == nil {
+		return recomputeErr
+	}
+
+	fixed := 0
+	for _, issue := range report.Issues {
+		if issue.Fixed {
+			fixed++
+		}
+	}
+	fmt.Printf("%d issues found, %d fixed\n", len(report.Issues), fixed)
+
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	if options.ReportPath == "" {
+		fmt.Println(string(data))
+	} else {
+		fmt.Printf("Writing repair report to %s\n", options.ReportPath)
+		if err := os.WriteFile(options.ReportPath, data, 0644); err != nil {
+			return err
+		}
+	}
+	if recomputeErr != nil {
+		return fmt.Errorf("error updating teams points and value: %w", recomputeErr)
+	}
+	return nil
+}
@@ -0,0 +1,199 @@
+package scripts
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+var playerCategories = map[string]bool{"Batsman": true, "Bowler": true, "All-Rounder": true}
+
+// RowResult is the outcome of validating and importing a single CSV row
+type RowResult struct {
+	Line     int      `json:"line"`
+	Name     string   `json:"name,omitempty"`
+	Errors   []string `json:"errors,omitempty"`
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// ImportReport is the machine-readable summary written at the end of every CSV import
+type ImportReport struct {
+	File     string      `json:"file"`
+	DryRun   bool        `json:"dry_run"`
+	Accepted []RowResult `json:"accepted"`
+	Warned   []RowResult `json:"warned"`
+	Rejected []RowResult `json:"rejected"`
+}
+
+func newImportReport(file string, dryRun bool) *ImportReport {
+	return &ImportReport{
+		File:     file,
+		DryRun:   dryRun,
+		Accepted: []RowResult{},
+		Warned:   []RowResult{},
+		Rejected: []RowResult{},
+	}
+}
+
+// add files the row as rejected when it has errors, warned when it has warnings and accepted otherwise
+func (r *ImportReport) add(row RowResult) {
+	if len(row.Errors) > 0 {
+		r.Rejected = append(r.Rejected, row)
+	} else if len(row.Warnings) > 0 {
+		r.Warned = append(r.Warned, row)
+	} else {
+		r.Accepted = append(r.Accepted, row)
+	}
+}
+
+// write stores the report as JSON in the given file, or prints it when no file is given
+func (r *ImportReport) write(path string) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%d accepted, %d warned, %d rejected\n", len(r.Accepted), len(r.Warned), len(r.Rejected))
+	if path == "" {
+		fmt.Println(string(data))
+		return nil
+	}
+	fmt.Printf("Writing import report to %s\n", path)
+	return os.WriteFile(path, data, 0644)
+}
+
+// rejectedError is returned when rows were rejected and the import was not forced
+func (r *ImportReport) rejectedError() error {
+	return fmt.Errorf("%d rows rejected in %s, fix them or use --force to import the accepted rows", len(r.Rejected), r.File)
+}
+
+// readCSV reads every row of the file after the header, reporting rows that cannot be parsed
+func readCSV(path string, columns int, report *ImportReport) ([]csvRow, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening file: %w", err)
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+
+	// Skip header row
+	_, err = reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("error reading header: %w", err)
+	}
+
+	var rows []csvRow
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			if parseErr, ok := err.(*csv.ParseError); ok {
+				report.add(RowResult{Line: parseErr.Line, Errors: []string{parseErr.Err.Error()}})
+				continue
+			}
+			return nil, fmt.Errorf("error reading rows: %w", err)
+		}
+
+		line, _ := reader.FieldPos(0)
+		if len(record) != columns {
+			report.add(RowResult{Line: line, Errors: []string{fmt.Sprintf("expected %d columns, got %d", columns, len(record))}})
+			continue
+		}
+		for i := range record {
+			record[i] = strings.TrimSpace(record[i])
+		}
+		rows = append(rows, csvRow{line: line, record: record})
+	}
+	return rows, nil
+}
+
+type csvRow struct {
+	line   int
+	record []string
+}
+
+// rowValidator collects the errors and warnings of a single row while parsing its fields
+type rowValidator struct {
+	result RowResult
+}
+
+func (v *rowValidator) errorf(format string, args ...interface{}) {
+	v.result.Errors = append(v.result.Errors, fmt.Sprintf(format, args...))
+}
+
+func (v *rowValidator) warnf(format string, args ...interface{}) {
+	v.result.Warnings = append(v.result.Warnings, fmt.Sprintf(format, args...))
+}
+
+func (v *rowValidator) required(field string, value string) string {
+	if value == "" {
+		v.errorf("%s is required", field)
+	}
+	return value
+}
+
+func (v *rowValidator) count(field string, value string) int {
+	number, err := strconv.Atoi(value)
+	if err != nil {
+		v.errorf("%s must be a whole number, got %q", field, value)
+		return 0
+	}
+	if number < 0 {
+		v.errorf("%s cannot be negative", field)
+	}
+	return number
+}
+
+func (v *rowValidator) decimal(field string, value string) float64 {
+	number, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		v.errorf("%s must be a number, got %q", field, value)
+		return 0
+	}
+	if number < 0 {
+		v.errorf("%s cannot be negative", field)
+	}
+	return number
+}
+
+// overs parses overs in cricket notation (e.g. 3.2 for three overs and two balls) and returns the balls
+func (v *rowValidator) overs(field string, value string) int {
+	parts := strings.SplitN(value, ".", 2)
+	completed := v.count(field, parts[0])
+	balls := 0
+	if len(parts) == 2 {
+		var err error
+		balls, err = strconv.Atoi(parts[1])
+		if err != nil || balls < 0 || balls > 5 {
+			v.errorf("%s must be in overs.balls notation with 0-5 balls, got %q", field, value)
+		}
+	}
+	return completed*6 + balls
+}
+
+// battingSanity checks that the runs could have been scored from the balls faced
+func (v *rowValidator) battingSanity(runs int, ballsFaced int) {
+	if runs > ballsFaced*6 {
+		v.errorf("%d runs cannot be scored from %d balls", runs, ballsFaced)
+	}
+}
+
+// bowlingSanity checks that the wickets and runs conceded are possible from the balls bowled
+func (v *rowValidator) bowlingSanity(wickets int, ballsBowled int, runsConceded int) {
+	if wickets > ballsBowled {
+		v.errorf("%d wickets cannot be taken in %d balls", wickets, ballsBowled)
+	}
+	if ballsBowled == 0 && runsConceded > 0 {
+		v.warnf("%d runs conceded without bowling a ball", runsConceded)
+	}
+	if ballsBowled > 0 && runsConceded > ballsBowled*6 {
+		v.warnf("%d runs conceded in %d balls is unusually high", runsConceded, ballsBowled)
+	}
+}
@@ -0,0 +1,155 @@
+package scripts
+
+import (
+	"fmt"
+	"go-orm-template/models"
+	"strconv"
+	"time"
+)
+
+// ScorecardImportOptions controls how ImportScorecardsFromCSV applies a scorecards file
+type ScorecardImportOptions struct {
+	Path       string
+	ReportPath string // Where to write the JSON report, printed when empty
+	Force      bool   // Import the accepted rows even when some rows are rejected
+}
+
+type scorecardRow struct {
+	result RowResult
+	match  *models.Match
+	player *models.Player
+	stat   *models.PlayerMatchStat
+}
+
+// ImportScorecardsFromCSV loads per-match scorecards with one row per player per match:
+//
+//	Match,Round,Date,Home University,Away University,Player,University,Runs,Balls Faced,Overs Bowled,Runs Conceded,Wickets
+//
+// Matches are created when they do not exist yet and each line is added to the player's totals,
+// so every round can be imported as it is played without touching earlier data.
+func ImportScorecardsFromCSV(options ScorecardImportOptions) error {
+	report := newImportReport(options.Path, false)
+
+	rows, err := readCSV(options.Path, 12, report)
+	if err != nil {
+		return err
+	}
+
+	seen := make(map[string]int)
+	var valid []*scorecardRow
+	for _, row := range rows {
+		parsed := parseScorecardRow(row)
+		if len(parsed.result.Errors) == 0 {
+			player, err := models.GetPlayerByNameAndUniversity(parsed.player.Name, parsed.player.University)
+			if err != nil {
+				parsed.result.Errors = append(parsed.result.Errors, fmt.Sprintf("player %s (%s) not found", parsed.player.Name, parsed.player.University))
+			} else {
+				parsed.player = player
+				key := parsed.match.Name + "#" + strconv.Itoa(parsed.match.Round) + "#" + strconv.Itoa(int(player.ID))
+				if line, ok := seen[key]; ok {
+					parsed.result.Errors = append(parsed.result.Errors, fmt.Sprintf("duplicate of line %d", line))
+				}
+				seen[key] = row.line
+			}
+		}
+		if len(parsed.result.Errors) > 0 {
+			report.add(parsed.result)
+			continue
+		}
+		valid = append(valid, parsed)
+	}
+
+	if len(report.Rejected) > 0 && !options.Force {
+		if err := report.write(options.ReportPath); err != nil {
+			return err
+		}
+		return report.rejectedError()
+	}
+
+	matches := make(map[string]*models.Match)
+	var changed []uint
+
+	for _, row := range valid {
+		matchKey := row.match.Name + "#" + strconv.Itoa(row.match.Round)
+		match, ok := matches[matchKey]
+		if !ok {
+			match = row.match
+			err = models.FindOrCreateMatch(match)
+			if err != nil {
+				row.result.Errors = append(row.result.Errors, fmt.Sprintf("error creating match: %v", err))
+				report.add(row.result)
+				continue
+			}
+			matches[matchKey] = match
+		}
+
+		row.stat.MatchID = match.ID
+		row.stat.PlayerID = row.player.ID
+		err = models.ApplyPlayerMatchStat(row.stat)
+		if err != nil {
+			row.result.Errors = append(row.result.Errors, fmt.Sprintf("error importing scorecard line: %v", err))
+			report.add(row.result)
+			continue
+		}
+
+		fmt.Printf("Successfully imported %s in %s\n", row.player.Name, match.Name)
+		changed = append(changed, row.player.ID)
+		report.add(row.result)
+	}
+
+	if err := recomputeTeams(changed); err != nil {
+		fmt.Printf("Error updating teams points and value: %v\n", err)
+	}
+
+	fmt.Println("Import completed")
+	if err := report.write(options.ReportPath); err != nil {
+		return err
+	}
+	if len(report.Rejected) > 0 && !options.Force {
+		return report.rejectedError()
+	}
+	return nil
+}
+
+// parseScorecardRow validates a row of a scorecards file and converts it to a match and scorecard line
+func parseScorecardRow(row csvRow) *scorecardRow {
+	record := row.record
+	v := &rowValidator{result: RowResult{Line: row.line, Name: record[5]}}
+
+	match := &models.Match{
+		Name:           v.required("match", record[0]),
+		Round:          v.count("round", record[1]),
+		HomeUniversity: v.required("home university", record[3]),
+		AwayUniversity: v.required("away university", record[4]),
+	}
+	startTime, err := time.Parse("2006-01-02", record[2])
+	if err != nil {
+		v.errorf("date must be in YYYY-MM-DD format, got %q", record[2])
+	}
+	match.StartTime = startTime
+
+	player := &models.Player{
+		Name:       v.required("player", record[5]),
+		University: v.required("university", record[6]),
+	}
+	if player.University != "" && player.University != match.HomeUniversity && player.University != match.AwayUniversity {
+		v.warnf("%s does not play in %s", player.University, match.Name)
+	}
+
+	stat := &models.PlayerMatchStat{
+		Runs:         v.count("runs", record[7]),
+		BallsFaced:   v.count("balls faced", record[8]),
+		BallsBowled:  v.overs("overs bowled", record[9]),
+		RunsConceded: v.count("runs conceded", record[10]),
+		Wickets:      v.count("wickets", record[11]),
+	}
+	stat.Batted = stat.BallsFaced > 0
+
+	v.battingSanity(stat.Runs, stat.BallsFaced)
+	v.bowlingSanity(stat.Wickets, stat.BallsBowled, stat.RunsConceded)
+	if stat.Wickets > 10 {
+		v.errorf("%d wickets cannot be taken in one innings", stat.Wickets)
+	}
+
+	return &scorecardRow{result: v.result, match: match, player: player, stat: stat}
+}